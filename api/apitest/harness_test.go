@@ -0,0 +1,353 @@
+package apitest_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"tbTool/api/apitest"
+	"tbTool/api/service/auth"
+	"tbTool/api/service/items"
+	"tbTool/api/service/pictures"
+	"tbTool/api/service/shop"
+	"tbTool/api/tools/common"
+	"tbTool/pkg/gitlab.xfq.com/wpt-api/g-api/errorcode/base"
+	"testing"
+)
+
+func newHarness(t *testing.T) *apitest.Harness {
+	h := apitest.New()
+	t.Cleanup(h.Close)
+	return h
+}
+
+func TestItemsOnSaleGet(t *testing.T) {
+	h := newHarness(t)
+	h.Gateway.Fixture(items.MethodItemsOnSaleGet, map[string]interface{}{
+		"items": map[string]interface{}{
+			"item": []map[string]interface{}{
+				{"num_iid": 1001, "title": "商品一", "price": "9.90"},
+				{"num_iid": 1002, "title": "商品二", "price": "19.90"},
+			},
+		},
+		"total_results": 2,
+	})
+
+	resp, err := h.PostJSON("/items/ItemsOnSaleGet", map[string]interface{}{"page_no": 1, "page_size": 40})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != 0 {
+		t.Fatalf("code = %d, msg = %s", resp.Code, resp.Msg)
+	}
+	var page items.ItemsOnSalePage
+	if err := apitest.DecodeData(resp, &page); err != nil {
+		t.Fatal(err)
+	}
+	if page.TotalResults != 2 || len(page.Items) != 2 || page.Items[1].NumIid != 1002 || page.Items[0].Title != "商品一" {
+		t.Errorf("page = %+v", page)
+	}
+
+	calls := h.Gateway.Calls(items.MethodItemsOnSaleGet)
+	if len(calls) != 1 {
+		t.Fatalf("gateway calls = %d, want 1", len(calls))
+	}
+	if got := calls[0].Params.Get("session"); got != apitest.Session {
+		t.Errorf("session = %q, want %q", got, apitest.Session)
+	}
+}
+
+func TestQuantityUpdate(t *testing.T) {
+	h := newHarness(t)
+	h.Gateway.Fixture(items.MethodItemQuantityUpdate, map[string]interface{}{
+		"item": map[string]interface{}{"num_iid": 1001, "num": 5},
+	})
+
+	resp, err := h.PostJSON("/items/quantity/update", map[string]interface{}{
+		"items": []map[string]interface{}{
+			{"num_iid": 1001, "quantity": 5},
+			// 缺少 quantity, 不发起调用
+			{"num_iid": 1002},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != 0 {
+		t.Fatalf("code = %d, msg = %s", resp.Code, resp.Msg)
+	}
+	var data struct {
+		Results []items.RowResult `json:"results"`
+	}
+	if err := apitest.DecodeData(resp, &data); err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Results) != 2 {
+		t.Fatalf("results = %+v", data.Results)
+	}
+	if data.Results[0].Status != items.RowSuccess {
+		t.Errorf("results[0] = %+v, want success", data.Results[0])
+	}
+	if data.Results[1].Status != items.RowSkipped {
+		t.Errorf("results[1] = %+v, want skipped", data.Results[1])
+	}
+
+	calls := h.Gateway.Calls(items.MethodItemQuantityUpdate)
+	if len(calls) != 1 {
+		t.Fatalf("gateway calls = %d, want 1", len(calls))
+	}
+	// harness 店铺配置了批量网关
+	if !calls[0].Batch {
+		t.Errorf("quantity update not sent through batch gateway")
+	}
+	if got := calls[0].Params.Get("num_iid"); got != "1001" {
+		t.Errorf("num_iid = %s, want 1001", got)
+	}
+	if got := calls[0].Params.Get("quantity"); got != "5" {
+		t.Errorf("quantity = %s, want 5", got)
+	}
+}
+
+func TestAuthRejected(t *testing.T) {
+	h := newHarness(t)
+	body := []byte(`{"page_no":1}`)
+
+	cases := []struct {
+		name   string
+		modify func(req *http.Request)
+		want   int32
+	}{
+		{
+			name:   "missing headers",
+			modify: func(req *http.Request) {},
+			want:   base.ParamError,
+		},
+		{
+			name: "bad signature",
+			modify: func(req *http.Request) {
+				h.Sign(req, body)
+				req.Header.Set(auth.HeaderSignature, "bad")
+			},
+			want: common.SignInvalid,
+		},
+		{
+			name: "body changed after signing",
+			modify: func(req *http.Request) {
+				h.Sign(req, []byte(`{"page_no":2}`))
+			},
+			want: common.SignInvalid,
+		},
+		{
+			name: "unknown caller",
+			modify: func(req *http.Request) {
+				h.Sign(req, body)
+				req.Header.Set(auth.HeaderAppID, "apitest-unknown")
+			},
+			want: common.CallerUnknown,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, apitest.BasePath+"/items/ItemsOnSaleGet", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Shop-Id", h.ShopID)
+			c.modify(req)
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			resp := decode(t, w)
+			if resp.Code != c.want {
+				t.Errorf("code = %d (%s), want %d", resp.Code, resp.Msg, c.want)
+			}
+		})
+	}
+
+	if calls := h.Gateway.Calls(""); len(calls) != 0 {
+		t.Errorf("gateway calls = %d, want 0", len(calls))
+	}
+}
+
+// 重放同一个签名请求
+func TestAuthRejectsReplay(t *testing.T) {
+	h := newHarness(t)
+	h.Gateway.Fixture(items.MethodItemsOnSaleGet, map[string]interface{}{"total_results": 0})
+	body := []byte(`{}`)
+
+	req := httptest.NewRequest(http.MethodPost, apitest.BasePath+"/items/ItemsOnSaleGet", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Shop-Id", h.ShopID)
+	h.Sign(req, body)
+	headers := req.Header.Clone()
+
+	for i, want := range []int32{0, common.RequestReplay} {
+		req := httptest.NewRequest(http.MethodPost, apitest.BasePath+"/items/ItemsOnSaleGet", bytes.NewReader(body))
+		req.Header = headers.Clone()
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if resp := decode(t, w); resp.Code != want {
+			t.Errorf("request %d code = %d (%s), want %d", i, resp.Code, resp.Msg, want)
+		}
+	}
+}
+
+// 各 harness 注册独立的店铺和调用方
+func TestHarnessScopedIDs(t *testing.T) {
+	a, b := newHarness(t), apitest.New()
+	if a.ShopID == b.ShopID || a.CallerID == b.CallerID {
+		t.Fatalf("harness ids collide: %s/%s", a.ShopID, a.CallerID)
+	}
+	b.Close()
+
+	// 关闭一个 harness 不影响另一个的店铺, 调用方和 nonce 存储
+	a.Gateway.Fixture(items.MethodItemsOnSaleGet, map[string]interface{}{"total_results": 0})
+	resp, err := a.PostJSON("/items/ItemsOnSaleGet", map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != 0 {
+		t.Errorf("code = %d (%s), want 0", resp.Code, resp.Msg)
+	}
+}
+
+func decode(t *testing.T, w *httptest.ResponseRecorder) *common.ResponseInterface {
+	t.Helper()
+	resp := &common.ResponseInterface{}
+	if err := json.Unmarshal(w.Body.Bytes(), resp); err != nil {
+		t.Fatalf("decode %q: %v", w.Body.String(), err)
+	}
+	return resp
+}
+
+// 响应中没有 item 时按数据缺失返回
+func TestItemDetailMissing(t *testing.T) {
+	h := newHarness(t)
+	h.Gateway.Fixture(items.MethodItemSellerGet, map[string]interface{}{})
+
+	resp, err := h.PostJSON("/items/detail", map[string]interface{}{"num_iid": 1001})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != base.MissingData {
+		t.Errorf("code = %d (%s), want %d", resp.Code, resp.Msg, base.MissingData)
+	}
+}
+
+// png 文件头, 足以按内容识别类型
+var pngData = append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 64)...)
+
+func uploadPicture(t *testing.T, h *apitest.Harness) *common.ResponseInterface {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", "logo.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(pngData)
+	mw.WriteField("title", "logo")
+	mw.Close()
+	return decode(t, h.Do(http.MethodPost, "/pictures/upload", nil, mw.FormDataContentType(), body.Bytes()))
+}
+
+func TestPictureUpload(t *testing.T) {
+	h := newHarness(t)
+	h.Gateway.Fixture(pictures.MethodPictureUpload, map[string]interface{}{
+		"picture": map[string]interface{}{"picture_id": 88, "picture_path": "https://img.example.com/logo.png"},
+	})
+
+	resp := uploadPicture(t, h)
+	if resp.Code != 0 {
+		t.Fatalf("code = %d, msg = %s", resp.Code, resp.Msg)
+	}
+	var data struct {
+		PictureID int64 `json:"picture_id"`
+	}
+	if err := apitest.DecodeData(resp, &data); err != nil {
+		t.Fatal(err)
+	}
+	if data.PictureID != 88 {
+		t.Errorf("picture_id = %d, want 88", data.PictureID)
+	}
+	calls := h.Gateway.Calls(pictures.MethodPictureUpload)
+	if len(calls) != 1 || !bytes.Equal(calls[0].Files["img"], pngData) {
+		t.Errorf("gateway did not receive the image")
+	}
+}
+
+// 响应中没有 picture 时返回业务错误而不是 panic
+func TestPictureUploadMissingPicture(t *testing.T) {
+	h := newHarness(t)
+	h.Gateway.Fixture(pictures.MethodPictureUpload, map[string]interface{}{})
+
+	resp := uploadPicture(t, h)
+	if resp.Code != base.DataStatus {
+		t.Errorf("code = %d (%s), want %d", resp.Code, resp.Msg, base.DataStatus)
+	}
+}
+
+// 改为非淘宝平台的店铺, 淘宝专用接口直接拒绝, 不访问网关
+func TestTaobaoRoutesRejectOtherPlatform(t *testing.T) {
+	h := newHarness(t)
+	s, err := shop.Get(h.ShopID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := shop.Store(&shop.Shop{
+		ID:        s.ID,
+		Name:      s.Name,
+		AppKey:    s.AppKey,
+		AppSecret: s.AppSecret,
+		Gateway:   s.Gateway,
+		Session:   s.Session,
+		Platform:  "jd",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := h.PostJSON("/items/ItemsOnSaleGet", map[string]interface{}{"page_no": 1, "page_size": 40})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != base.DataStatus {
+		t.Errorf("items code = %d (%s), want %d", resp.Code, resp.Msg, base.DataStatus)
+	}
+	// market 接口按平台解析凭证, 未注册的平台同样拒绝
+	resp, err = h.Get("/market/items", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != base.DataStatus {
+		t.Errorf("market code = %d (%s), want %d", resp.Code, resp.Msg, base.DataStatus)
+	}
+	if calls := h.Gateway.Calls(items.MethodItemsOnSaleGet); len(calls) != 0 {
+		t.Errorf("gateway calls = %d, want 0", len(calls))
+	}
+}
+
+// 淘宝店铺的 market 接口由淘宝实现解析 session
+func TestMarketItems(t *testing.T) {
+	h := newHarness(t)
+	h.Gateway.Fixture(items.MethodItemsOnSaleGet, map[string]interface{}{
+		"items": map[string]interface{}{
+			"item": []map[string]interface{}{{"num_iid": 1001, "title": "商品一", "price": "9.90"}},
+		},
+		"total_results": 1,
+	})
+
+	resp, err := h.Get("/market/items", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != 0 {
+		t.Fatalf("code = %d, msg = %s", resp.Code, resp.Msg)
+	}
+	calls := h.Gateway.Calls(items.MethodItemsOnSaleGet)
+	if len(calls) != 1 {
+		t.Fatalf("gateway calls = %d, want 1", len(calls))
+	}
+	if got := calls[0].Params.Get("session"); got != apitest.Session {
+		t.Errorf("session = %q, want %q", got, apitest.Session)
+	}
+}
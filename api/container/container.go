@@ -0,0 +1,123 @@
+package container
+
+import (
+	"go.uber.org/dig"
+	"log"
+	categoriesHandler "tbTool/api/handler/categories"
+	exportsHandler "tbTool/api/handler/exports"
+	itemsHandler "tbTool/api/handler/items"
+	listingsHandler "tbTool/api/handler/listings"
+	logisticsHandler "tbTool/api/handler/logistics"
+	marketplaceHandler "tbTool/api/handler/marketplace"
+	oauthHandler "tbTool/api/handler/oauth"
+	picturesHandler "tbTool/api/handler/pictures"
+	refundsHandler "tbTool/api/handler/refunds"
+	tradesHandler "tbTool/api/handler/trades"
+	"tbTool/api/service/alerts"
+	"tbTool/api/service/categories"
+	"tbTool/api/service/exports"
+	"tbTool/api/service/items"
+	"tbTool/api/service/listings"
+	"tbTool/api/service/logistics"
+	"tbTool/api/service/marketplace"
+	"tbTool/api/service/marketplace/taobao"
+	"tbTool/api/service/oauth"
+	"tbTool/api/service/pictures"
+	"tbTool/api/service/refunds"
+	"tbTool/api/service/shop"
+	"tbTool/api/service/snapshots"
+	"tbTool/api/service/tmc"
+	"tbTool/api/service/trades"
+)
+
+// 依赖注入容器, 服务启动和测试桩共用
+func New() *dig.Container {
+	c := dig.New()
+
+	callerErr := c.Provide(shop.NewCaller)
+	itemCacheErr := c.Provide(items.NewOnSaleCache)
+	itemSrvErr := c.Provide(items.NewItemServiceImpl)
+	itemHandErr := c.Provide(itemsHandler.NewItemsOnSaleGetHandler)
+	itemDetailHandErr := c.Provide(itemsHandler.NewItemSellerGetHandler)
+	itemUpdateHandErr := c.Provide(itemsHandler.NewItemUpdateHandler)
+	if callerErr != nil || itemCacheErr != nil || itemSrvErr != nil || itemHandErr != nil || itemDetailHandErr != nil || itemUpdateHandErr != nil {
+		log.Fatalf("initContainer start items result:%v,%v,%v,%v,%v,%v", callerErr, itemCacheErr, itemSrvErr, itemHandErr, itemDetailHandErr, itemUpdateHandErr)
+	}
+
+	alertEngineErr := c.Provide(alerts.NewEngine)
+	snapshotSrvErr := c.Provide(snapshots.NewSnapshotServiceImpl)
+	snapshotJobErr := c.Provide(snapshots.NewSnapshotJob)
+	historyHandErr := c.Provide(itemsHandler.NewItemHistoryHandler)
+	if alertEngineErr != nil || snapshotSrvErr != nil || snapshotJobErr != nil || historyHandErr != nil {
+		log.Fatalf("initContainer start snapshots result:%v,%v,%v,%v", alertEngineErr, snapshotSrvErr, snapshotJobErr, historyHandErr)
+	}
+
+	listingSrvErr := c.Provide(listings.NewListingServiceImpl)
+	listingJobErr := c.Provide(listings.NewScheduleJob)
+	listingHandErr := c.Provide(listingsHandler.NewListingsHandler)
+	if listingSrvErr != nil || listingJobErr != nil || listingHandErr != nil {
+		log.Fatalf("initContainer start listings result:%v,%v,%v", listingSrvErr, listingJobErr, listingHandErr)
+	}
+
+	// 新平台以 dig.Group(marketplace.PlatformGroup) 注册 marketplace.Platform 即可接入
+	taobaoPlatformErr := c.Provide(taobao.NewProvider, dig.Group(marketplace.PlatformGroup))
+	marketProviderErr := c.Provide(marketplace.NewShopProvider)
+	marketHandErr := c.Provide(marketplaceHandler.NewMarketplaceHandler)
+	if taobaoPlatformErr != nil || marketProviderErr != nil || marketHandErr != nil {
+		log.Fatalf("initContainer start marketplace result:%v,%v,%v", taobaoPlatformErr, marketProviderErr, marketHandErr)
+	}
+
+	pictureSrvErr := c.Provide(pictures.NewPictureServiceImpl)
+	pictureHandErr := c.Provide(picturesHandler.NewPictureUploadHandler)
+	if pictureSrvErr != nil || pictureHandErr != nil {
+		log.Fatalf("initContainer start pictures result:%v,%v", pictureSrvErr, pictureHandErr)
+	}
+
+	categoryCacheErr := c.Provide(categories.NewCache)
+	categorySrvErr := c.Provide(categories.NewCategoryServiceImpl)
+	categoryHandErr := c.Provide(categoriesHandler.NewCategoriesHandler)
+	if categoryCacheErr != nil || categorySrvErr != nil || categoryHandErr != nil {
+		log.Fatalf("initContainer start categories result:%v,%v,%v", categoryCacheErr, categorySrvErr, categoryHandErr)
+	}
+
+	exportStorageErr := c.Provide(exports.NewLocalStorage)
+	exportSrvErr := c.Provide(exports.NewExportServiceImpl)
+	exportHandErr := c.Provide(exportsHandler.NewItemsExportHandler)
+	if exportStorageErr != nil || exportSrvErr != nil || exportHandErr != nil {
+		log.Fatalf("initContainer start exports result:%v,%v,%v", exportStorageErr, exportSrvErr, exportHandErr)
+	}
+
+	sessionSrvErr := c.Provide(oauth.NewSessionServiceImpl)
+	authorizeHandErr := c.Provide(oauthHandler.NewAuthorizeHandler)
+	if sessionSrvErr != nil || authorizeHandErr != nil {
+		log.Fatalf("initContainer start oauth result:%v,%v", sessionSrvErr, authorizeHandErr)
+	}
+
+	tradeSrvErr := c.Provide(trades.NewTradeServiceImpl)
+	tradeJobErr := c.Provide(trades.NewSyncJob)
+	tradeHandErr := c.Provide(tradesHandler.NewTradesSyncHandler)
+	tradeDetailHandErr := c.Provide(tradesHandler.NewTradeFullinfoGetHandler)
+	if tradeSrvErr != nil || tradeJobErr != nil || tradeHandErr != nil || tradeDetailHandErr != nil {
+		log.Fatalf("initContainer start trades result:%v,%v,%v,%v", tradeSrvErr, tradeJobErr, tradeHandErr, tradeDetailHandErr)
+	}
+
+	logisticsSrvErr := c.Provide(logistics.NewLogisticsServiceImpl)
+	logisticsHandErr := c.Provide(logisticsHandler.NewLogisticsOfflineSendHandler)
+	if logisticsSrvErr != nil || logisticsHandErr != nil {
+		log.Fatalf("initContainer start logistics result:%v,%v", logisticsSrvErr, logisticsHandErr)
+	}
+
+	refundSrvErr := c.Provide(refunds.NewRefundServiceImpl)
+	refundHandErr := c.Provide(refundsHandler.NewRefundsReceiveGetHandler)
+	if refundSrvErr != nil || refundHandErr != nil {
+		log.Fatalf("initContainer start refunds result:%v,%v", refundSrvErr, refundHandErr)
+	}
+
+	tmcPubErr := c.Provide(tmc.NewRabbitPublisher)
+	tmcJobErr := c.Provide(tmc.NewJob)
+	if tmcPubErr != nil || tmcJobErr != nil {
+		log.Fatalf("initContainer start tmc result:%v,%v", tmcPubErr, tmcJobErr)
+	}
+
+	return c
+}
@@ -0,0 +1,106 @@
+package categories
+
+import (
+	"github.com/gin-gonic/gin"
+	"tbTool/api/service/categories"
+	"tbTool/api/tools/common"
+	"tbTool/pkg"
+	"tbTool/pkg/gitlab.xfq.com/wpt-api/g-api/errorcode/base"
+)
+
+type ChildrenRequest struct {
+	ParentCid int64 `json:"parent_cid" form:"parent_cid" binding:"min=0"`
+}
+
+type CategoryRequest struct {
+	Cid int64 `json:"cid" form:"cid" binding:"gt=0"`
+}
+
+type PropsRequest struct {
+	Cid int64 `json:"cid" form:"cid" binding:"gt=0"`
+	// 默认只返回必填属性
+	All bool `json:"all" form:"all"`
+}
+
+type CategoriesHandler struct {
+	cs categories.CategoryService
+}
+
+func NewCategoriesHandler(cs categories.CategoryService) *CategoriesHandler {
+	return &CategoriesHandler{
+		cs: cs,
+	}
+}
+
+// 浏览子类目, parent_cid 为0时返回一级类目
+func (ch *CategoriesHandler) Children(c *gin.Context) pkg.Render {
+	var req ChildrenRequest
+	if r, ok := common.Bind(c, &req); !ok {
+		return r
+	}
+
+	cats, err := ch.cs.Children(c.Request.Context(), req.ParentCid)
+	if err != nil {
+		return categoryErr(err)
+	}
+
+	return common.Success(map[string]interface{}{
+		"parent_cid": req.ParentCid,
+		"item_cats":  cats,
+	})
+}
+
+// 解析类目路径, 从一级类目到当前类目
+func (ch *CategoriesHandler) Path(c *gin.Context) pkg.Render {
+	var req CategoryRequest
+	if r, ok := common.Bind(c, &req); !ok {
+		return r
+	}
+
+	path, err := ch.cs.Path(c.Request.Context(), req.Cid)
+	if err != nil {
+		return categoryErr(err)
+	}
+
+	return common.Success(map[string]interface{}{
+		"cid":  req.Cid,
+		"path": path,
+	})
+}
+
+// 叶子类目发布商品需要填写的属性
+func (ch *CategoriesHandler) Props(c *gin.Context) pkg.Render {
+	var req PropsRequest
+	if r, ok := common.Bind(c, &req); !ok {
+		return r
+	}
+
+	props, err := ch.cs.Props(c.Request.Context(), req.Cid, !req.All)
+	if err != nil {
+		return categoryErr(err)
+	}
+
+	return common.Success(map[string]interface{}{
+		"cid":        req.Cid,
+		"item_props": props,
+	})
+}
+
+// 卖家已授权的类目和品牌
+func (ch *CategoriesHandler) Authorized(c *gin.Context) pkg.Render {
+	auth, err := ch.cs.Authorized(c.Request.Context(), c.GetString("session"))
+	if err != nil {
+		return categoryErr(err)
+	}
+	return common.Succ(auth)
+}
+
+func categoryErr(err error) pkg.Render {
+	switch err {
+	case categories.ErrUnknownCategory:
+		return common.ResErr(base.MissingData, base.ErrorMsg(base.MissingData))
+	case categories.ErrNotLeaf:
+		return common.ResParamIllegal(common.Illegal("cid", "leaf").Error())
+	}
+	return common.ResErrFrom(err)
+}
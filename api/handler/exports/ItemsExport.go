@@ -0,0 +1,132 @@
+package exports
+
+import (
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"tbTool/api/service/exports"
+	"tbTool/api/tools/common"
+	"tbTool/pkg"
+	"tbTool/pkg/gitlab.xfq.com/wpt-api/g-api/errorcode/base"
+)
+
+type ItemsExportRequest struct {
+	// csv 或 xlsx, 默认 csv
+	Format  string   `json:"format" form:"format" binding:"omitempty,oneof=csv xlsx"`
+	Columns []string `json:"columns" form:"columns"`
+}
+
+func (r *ItemsExportRequest) Validate() error {
+	return common.CheckList("columns", strings.Join(r.Columns, ","), exports.ColumnNames())
+}
+
+type ExportJobRequest struct {
+	ID string `json:"id" form:"id" binding:"required"`
+}
+
+type ItemsExportHandler struct {
+	es exports.ExportService
+}
+
+func NewItemsExportHandler(es exports.ExportService) *ItemsExportHandler {
+	return &ItemsExportHandler{
+		es: es,
+	}
+}
+
+// 创建商品导出任务, 返回任务id供轮询
+func (eh *ItemsExportHandler) Start(c *gin.Context) pkg.Render {
+	var req ItemsExportRequest
+	if r, ok := common.Bind(c, &req); !ok {
+		return r
+	}
+	if req.Format == "" {
+		req.Format = exports.FormatCSV
+	}
+
+	job, err := eh.es.Start(c.Request.Context(), c.GetString("session"), exports.Request{
+		Format:  req.Format,
+		Columns: req.Columns,
+	})
+	switch err {
+	case nil:
+	case exports.ErrUnknownFormat:
+		return common.ResParamIllegal("format")
+	case exports.ErrUnknownColumn:
+		return common.ResParamIllegal("columns")
+	default:
+		return common.ResErrFrom(err)
+	}
+
+	return common.Succ(job)
+}
+
+// 查询导出任务状态
+func (eh *ItemsExportHandler) Status(c *gin.Context) pkg.Render {
+	var req ExportJobRequest
+	if r, ok := common.Bind(c, &req); !ok {
+		return r
+	}
+
+	job, err := eh.es.Status(c.Request.Context(), req.ID)
+	if err != nil {
+		return jobErr(err)
+	}
+	return common.Succ(job)
+}
+
+// 下载已完成任务的导出文件
+func (eh *ItemsExportHandler) Download(c *gin.Context) pkg.Render {
+	var req ExportJobRequest
+	if r, ok := common.Bind(c, &req); !ok {
+		return r
+	}
+
+	job, f, err := eh.es.Open(c.Request.Context(), req.ID)
+	if err != nil {
+		return jobErr(err)
+	}
+
+	contentType := "text/csv; charset=utf-8"
+	if job.Format == exports.FormatXLSX {
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return &closeAfterRender{
+		r: pkg.Reader{
+			ContentType:   contentType,
+			ContentLength: job.Size,
+			Reader:        f,
+			Headers: map[string]string{
+				"Content-Disposition": "attachment; filename*=UTF-8''" + url.PathEscape(job.FileName()),
+			},
+		},
+		c: f,
+	}
+}
+
+func jobErr(err error) pkg.Render {
+	switch err {
+	case exports.ErrUnknownJob:
+		return common.ResErr(base.MissingData, base.ErrorMsg(base.MissingData))
+	case exports.ErrJobNotDone:
+		return common.ResErr(base.DataStatus, base.ErrorMsg(base.DataStatus))
+	}
+	return common.ResErrFrom(err)
+}
+
+// 渲染结束后关闭文件, 客户端中途断开时写入出错同样会关闭
+type closeAfterRender struct {
+	r pkg.Render
+	c io.Closer
+}
+
+func (r *closeAfterRender) Render(w http.ResponseWriter) error {
+	defer r.c.Close()
+	return r.r.Render(w)
+}
+
+func (r *closeAfterRender) WriteContentType(w http.ResponseWriter) {
+	r.r.WriteContentType(w)
+}
@@ -0,0 +1,57 @@
+package exports
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"tbTool/pkg"
+	"testing"
+)
+
+type trackedFile struct {
+	*strings.Reader
+	closed bool
+}
+
+func (f *trackedFile) Close() error {
+	f.closed = true
+	return nil
+}
+
+// 客户端断开, 写入失败
+type brokenWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w brokenWriter) Write([]byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func (w brokenWriter) WriteString(string) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func TestCloseAfterRender(t *testing.T) {
+	cases := []struct {
+		name    string
+		w       http.ResponseWriter
+		wantErr bool
+	}{
+		{name: "complete", w: httptest.NewRecorder()},
+		{name: "client disconnected", w: brokenWriter{httptest.NewRecorder()}, wantErr: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			f := &trackedFile{Reader: strings.NewReader(strings.Repeat("num_iid,title\n", 1024))}
+			r := &closeAfterRender{r: pkg.Reader{ContentType: "text/csv", ContentLength: f.Size(), Reader: f}, c: f}
+			err := r.Render(c.w)
+			if (err != nil) != c.wantErr {
+				t.Errorf("Render error = %v, wantErr %v", err, c.wantErr)
+			}
+			if !f.closed {
+				t.Error("file not closed after render")
+			}
+		})
+	}
+}
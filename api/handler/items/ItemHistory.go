@@ -0,0 +1,62 @@
+package items
+
+import (
+	"github.com/gin-gonic/gin"
+	"tbTool/api/service/snapshots"
+	"tbTool/api/tools/common"
+	"tbTool/pkg"
+)
+
+type ItemHistoryRequest struct {
+	NumIid int64  `json:"num_iid" form:"num_iid" binding:"gt=0"`
+	Field  string `json:"field" form:"field" binding:"omitempty,oneof=title price num outer_id pic_url cid approve_status on_sale"`
+	// yyyy-MM-dd HH:mm:ss, 东八区
+	StartTime string `json:"start_time" form:"start_time"`
+	EndTime   string `json:"end_time" form:"end_time"`
+	Limit     int    `json:"limit" form:"limit" binding:"min=0,max=1000"`
+}
+
+func (r *ItemHistoryRequest) Validate() error {
+	if _, err := common.ParseOptionalTime(r.StartTime); err != nil {
+		return common.Illegal("start_time", "format")
+	}
+	if _, err := common.ParseOptionalTime(r.EndTime); err != nil {
+		return common.Illegal("end_time", "format")
+	}
+	return nil
+}
+
+type ItemHistoryHandler struct {
+	sn snapshots.SnapshotService
+}
+
+func NewItemHistoryHandler(sn snapshots.SnapshotService) *ItemHistoryHandler {
+	return &ItemHistoryHandler{
+		sn: sn,
+	}
+}
+
+// 查询商品标题、价格等字段的变更历史
+func (ih *ItemHistoryHandler) ItemHistory(c *gin.Context) pkg.Render {
+	var req ItemHistoryRequest
+	if r, ok := common.Bind(c, &req); !ok {
+		return r
+	}
+
+	q := snapshots.HistoryQuery{NumIid: req.NumIid, Field: req.Field, Limit: req.Limit}
+	q.From, _ = common.ParseOptionalTime(req.StartTime)
+	q.To, _ = common.ParseOptionalTime(req.EndTime)
+	if !q.From.IsZero() && !q.To.IsZero() && q.To.Before(q.From) {
+		return common.ResParamIllegal(common.Illegal("end_time", "gtefield=start_time").Error())
+	}
+
+	changes, err := ih.sn.History(c.Request.Context(), q)
+	if err != nil {
+		return common.ResErrFrom(err)
+	}
+
+	return common.Success(map[string]interface{}{
+		"num_iid": req.NumIid,
+		"changes": changes,
+	})
+}
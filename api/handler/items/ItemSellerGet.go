@@ -0,0 +1,47 @@
+package items
+
+import (
+	"github.com/gin-gonic/gin"
+	"tbTool/api/service/items"
+	"tbTool/api/tools/common"
+	"tbTool/pkg"
+	"tbTool/pkg/gitlab.xfq.com/wpt-api/g-api/errorcode/base"
+)
+
+type ItemSellerGetRequest struct {
+	NumIid int64  `json:"num_iid" form:"num_iid" binding:"gt=0"`
+	Fields string `json:"fields" form:"fields"`
+}
+
+func (r *ItemSellerGetRequest) Validate() error {
+	return common.CheckList("fields", r.Fields, items.DetailFieldList)
+}
+
+type ItemSellerGetHandler struct {
+	is items.ItemService
+}
+
+func NewItemSellerGetHandler(is items.ItemService) *ItemSellerGetHandler {
+	return &ItemSellerGetHandler{
+		is: is,
+	}
+}
+
+// 获取单个商品详情, 包含SKU、属性、图片和描述
+func (ih *ItemSellerGetHandler) TaoBaoItemSellerGet(c *gin.Context) pkg.Render {
+	var req ItemSellerGetRequest
+	if r, ok := common.Bind(c, &req); !ok {
+		return r
+	}
+
+	detail, err := ih.is.ItemSellerGet(c.Request.Context(), c.GetString("session"), req.NumIid, req.Fields)
+	if err != nil {
+		return common.ResErrFrom(err)
+	}
+	// 与 marketplace 接口一致, 商品不存在时返回数据缺失
+	if detail == nil {
+		return common.ResErr(base.MissingData, base.ErrorMsg(base.MissingData))
+	}
+
+	return common.Succ(detail)
+}
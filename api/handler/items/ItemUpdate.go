@@ -0,0 +1,82 @@
+package items
+
+import (
+	"github.com/gin-gonic/gin"
+	"tbTool/api/service/items"
+	"tbTool/api/tools/common"
+	"tbTool/pkg"
+)
+
+type QuantityUpdateRequest struct {
+	Items []items.QuantityUpdate `json:"items" binding:"required,min=1,max=200"`
+}
+
+type PriceUpdateRequest struct {
+	Items []items.PriceUpdate `json:"items" binding:"required,min=1,max=200"`
+}
+
+type ListingRequest struct {
+	Items []items.ListingUpdate `json:"items" binding:"required,min=1,max=200"`
+}
+
+type DelistingRequest struct {
+	NumIids []int64 `json:"num_iids" binding:"required,min=1,max=200"`
+}
+
+type ItemUpdateHandler struct {
+	is items.ItemService
+}
+
+func NewItemUpdateHandler(is items.ItemService) *ItemUpdateHandler {
+	return &ItemUpdateHandler{
+		is: is,
+	}
+}
+
+// 批量更新库存(商品或SKU)
+func (ih *ItemUpdateHandler) QuantityUpdate(c *gin.Context) pkg.Render {
+	var req QuantityUpdateRequest
+	if r, ok := common.Bind(c, &req); !ok {
+		return r
+	}
+
+	results := ih.is.UpdateQuantity(c.Request.Context(), c.GetString("session"), req.Items)
+
+	return common.Succ(map[string]interface{}{"results": results})
+}
+
+// 批量更新价格(商品一口价或SKU价格)
+func (ih *ItemUpdateHandler) PriceUpdate(c *gin.Context) pkg.Render {
+	var req PriceUpdateRequest
+	if r, ok := common.Bind(c, &req); !ok {
+		return r
+	}
+
+	results := ih.is.UpdatePrice(c.Request.Context(), c.GetString("session"), req.Items)
+
+	return common.Succ(map[string]interface{}{"results": results})
+}
+
+// 批量上架
+func (ih *ItemUpdateHandler) Listing(c *gin.Context) pkg.Render {
+	var req ListingRequest
+	if r, ok := common.Bind(c, &req); !ok {
+		return r
+	}
+
+	results := ih.is.Listing(c.Request.Context(), c.GetString("session"), req.Items)
+
+	return common.Succ(map[string]interface{}{"results": results})
+}
+
+// 批量下架
+func (ih *ItemUpdateHandler) Delisting(c *gin.Context) pkg.Render {
+	var req DelistingRequest
+	if r, ok := common.Bind(c, &req); !ok {
+		return r
+	}
+
+	results := ih.is.Delisting(c.Request.Context(), c.GetString("session"), req.NumIids)
+
+	return common.Succ(map[string]interface{}{"results": results})
+}
@@ -1,37 +1,24 @@
 package items
 
 import (
-	"encoding/json"
 	"github.com/gin-gonic/gin"
 	"tbTool/api/service/items"
 	"tbTool/api/tools/common"
 	"tbTool/pkg"
-	"tbTool/pkg/request"
-	"time"
 )
 
-const (
-	TestSession = "6102205c5833518108ZZd5e2cc696ba487a790d5509e42c2211416907585"
-	MethodName  = "taobao.items.onsale.get"
-)
-
-type ItemOnSaleGet struct {
-	ItemsOnSaleGetResponse struct {
-		Items struct {
-			Item []struct {
-				NumIid int64 `json:"num_iid"`
-			} `json:"item"`
-		} `json:"items"`
-		TotalResults int    `json:"total_results"`
-		RequestID    string `json:"request_id"`
-	} `json:"items_onsale_get_response"`
+type ItemsOnSaleGetRequest struct {
+	// 逗号分隔, 为空时返回 num_iid,title,price
+	Fields   string `json:"fields" form:"fields"`
+	PageNo   int    `json:"page_no" form:"page_no" binding:"min=0"`
+	PageSize int    `json:"page_size" form:"page_size" binding:"min=0,max=200"`
+	All      bool   `json:"all" form:"all"`
+}
 
-	ErrorResponse struct {
-		Code      int    `json:"code"`
-		Msg       string `json:"msg"`
-		RequestID string `json:"request_id"`
-	} `json:"error_response"`
+func (r *ItemsOnSaleGetRequest) Validate() error {
+	return common.CheckList("fields", r.Fields, items.OnSaleFieldList)
 }
+
 type ItemOnSaleGetHandler struct {
 	is items.ItemService
 }
@@ -42,34 +29,25 @@ func NewItemsOnSaleGetHandler(is items.ItemService) *ItemOnSaleGetHandler {
 	}
 }
 
-//获取当前会话用户出售中的商品列表
-func (ih *ItemOnSaleGetHandler) TaoBaoItemsOnSaleGet(c *gin.Context) pkg.Render{
-	var info ItemOnSaleGet
-
-	sign := c.MustGet("sign").(string)
-
-	//参数
-	requestMap := map[string]string{
-		"fields": "num_iid,title,price",
+// 获取当前会话用户出售中的商品列表
+func (ih *ItemOnSaleGetHandler) TaoBaoItemsOnSaleGet(c *gin.Context) pkg.Render {
+	var req ItemsOnSaleGetRequest
+	if r, ok := common.Bind(c, &req); !ok {
+		return r
 	}
-
-	_url := ih.is.GetTaoBaoItemsUrl(MethodName, sign, TestSession, requestMap)
-	_, data, err := request.Get(_url, 2*time.Second, 3)
-
-	if err != nil {
-
+	if req.Fields == "" {
+		req.Fields = items.DefaultOnSaleFields
 	}
 
-	//处理接口返回数据
-	if errJson := json.Unmarshal(data, &info); errJson != nil {
-
-	}
-
-	if info.ErrorResponse.Code != 0 {
-		//
-	}else{
-
+	page, err := ih.is.ItemsOnSaleGet(c.Request.Context(), c.GetString("session"), items.OnSaleQuery{
+		Fields:   req.Fields,
+		PageNo:   req.PageNo,
+		PageSize: req.PageSize,
+		All:      req.All,
+	})
+	if err != nil {
+		return common.ResErrFrom(err)
 	}
 
-	return common.Succ(info)
+	return common.Succ(page)
 }
@@ -0,0 +1,127 @@
+package listings
+
+import (
+	"github.com/gin-gonic/gin"
+	"tbTool/api/service/items"
+	"tbTool/api/service/listings"
+	"tbTool/api/service/top"
+	"tbTool/api/tools/common"
+	"tbTool/pkg"
+	"tbTool/pkg/gitlab.xfq.com/wpt-api/g-api/errorcode/base"
+)
+
+type ScheduleRequest struct {
+	Type string `json:"type" form:"type" binding:"required,oneof=listing delisting"`
+	// yyyy-MM-dd HH:mm:ss, 东八区
+	ExecuteAt string `json:"execute_at" form:"execute_at" binding:"required"`
+	// 下架时只需 num_iid
+	Items []items.ListingUpdate `json:"items" binding:"required,min=1,max=200"`
+}
+
+func (r *ScheduleRequest) Validate() error {
+	if _, err := top.ParseTime(r.ExecuteAt); err != nil {
+		return common.Illegal("execute_at", "format")
+	}
+	for _, it := range r.Items {
+		if it.NumIid <= 0 {
+			return common.Illegal("items.num_iid", "gt=0")
+		}
+		if r.Type == listings.TypeListing && it.Num <= 0 {
+			return common.Illegal("items.num", "gt=0")
+		}
+	}
+	return nil
+}
+
+type ActionRequest struct {
+	ID int64 `json:"id" form:"id" binding:"gt=0"`
+}
+
+type ActionListRequest struct {
+	Status string `json:"status" form:"status" binding:"omitempty,oneof=pending running done failed canceled"`
+	Limit  int    `json:"limit" form:"limit" binding:"min=0,max=200"`
+}
+
+type ListingsHandler struct {
+	ls listings.ListingService
+}
+
+func NewListingsHandler(ls listings.ListingService) *ListingsHandler {
+	return &ListingsHandler{
+		ls: ls,
+	}
+}
+
+// 创建定时上下架任务
+func (lh *ListingsHandler) Schedule(c *gin.Context) pkg.Render {
+	var req ScheduleRequest
+	if r, ok := common.Bind(c, &req); !ok {
+		return r
+	}
+
+	executeAt, _ := top.ParseTime(req.ExecuteAt)
+	action, err := lh.ls.Schedule(c.Request.Context(), listings.ScheduleRequest{
+		Type:      req.Type,
+		SellerID:  common.SellerID(c),
+		ExecuteAt: executeAt,
+		Items:     req.Items,
+	})
+	if err != nil {
+		return actionErr(err)
+	}
+	return common.Succ(action)
+}
+
+// 查询任务及逐个商品的执行结果
+func (lh *ListingsHandler) Action(c *gin.Context) pkg.Render {
+	var req ActionRequest
+	if r, ok := common.Bind(c, &req); !ok {
+		return r
+	}
+
+	action, err := lh.ls.Get(c.Request.Context(), req.ID)
+	if err != nil {
+		return actionErr(err)
+	}
+	return common.Succ(action)
+}
+
+// 任务列表
+func (lh *ListingsHandler) Actions(c *gin.Context) pkg.Render {
+	var req ActionListRequest
+	if r, ok := common.Bind(c, &req); !ok {
+		return r
+	}
+
+	actions, err := lh.ls.List(c.Request.Context(), listings.ActionQuery{Status: req.Status, Limit: req.Limit})
+	if err != nil {
+		return actionErr(err)
+	}
+	return common.Success(map[string]interface{}{"actions": actions})
+}
+
+// 取消未执行的任务
+func (lh *ListingsHandler) Cancel(c *gin.Context) pkg.Render {
+	var req ActionRequest
+	if r, ok := common.Bind(c, &req); !ok {
+		return r
+	}
+
+	action, err := lh.ls.Cancel(c.Request.Context(), req.ID)
+	if err != nil {
+		return actionErr(err)
+	}
+	return common.Succ(action)
+}
+
+func actionErr(err error) pkg.Render {
+	switch err {
+	case listings.ErrUnknownAction:
+		return common.ResErr(base.MissingData, base.ErrorMsg(base.MissingData))
+	case listings.ErrActionNotPending:
+		return common.ResErr(base.DataStatus, base.ErrorMsg(base.DataStatus))
+	case listings.ErrExecuteAtPassed:
+		return common.ResParamIllegal(common.Illegal("execute_at", "future").Error())
+	}
+	return common.ResErrFrom(err)
+}
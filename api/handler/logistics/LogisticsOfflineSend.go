@@ -0,0 +1,37 @@
+package logistics
+
+import (
+	"github.com/gin-gonic/gin"
+	"tbTool/api/service/logistics"
+	"tbTool/api/tools/common"
+	"tbTool/pkg"
+	"tbTool/pkg/gitlab.xfq.com/wpt-api/g-api/errorcode/base"
+)
+
+type LogisticsOfflineSendHandler struct {
+	ls logistics.LogisticsService
+}
+
+func NewLogisticsOfflineSendHandler(ls logistics.LogisticsService) *LogisticsOfflineSendHandler {
+	return &LogisticsOfflineSendHandler{
+		ls: ls,
+	}
+}
+
+// 填写运单号发货
+func (lh *LogisticsOfflineSendHandler) TaoBaoLogisticsOfflineSend(c *gin.Context) pkg.Render {
+	var req logistics.OfflineSend
+	if r, ok := common.Bind(c, &req); !ok {
+		return r
+	}
+
+	shipping, err := lh.ls.OfflineSend(c.Request.Context(), c.GetString("session"), req)
+	if err == logistics.ErrSendFailed {
+		return common.ResErr(base.DataStatus, base.ErrorMsg(base.DataStatus)+":is_success")
+	}
+	if err != nil {
+		return common.ResErrFrom(err)
+	}
+
+	return common.Succ(shipping)
+}
@@ -0,0 +1,123 @@
+package marketplace
+
+import (
+	"github.com/gin-gonic/gin"
+	"tbTool/api/service/marketplace"
+	"tbTool/api/tools/common"
+	"tbTool/pkg"
+	"tbTool/pkg/gitlab.xfq.com/wpt-api/g-api/errorcode/base"
+)
+
+type ItemsRequest struct {
+	PageNo   int `json:"page_no" form:"page_no" binding:"min=0"`
+	PageSize int `json:"page_size" form:"page_size" binding:"min=0,max=200"`
+}
+
+type ItemRequest struct {
+	ID string `json:"id" form:"id" binding:"required"`
+}
+
+type StockUpdateRequest struct {
+	Items []marketplace.StockUpdate `json:"items" binding:"required,min=1,max=200"`
+}
+
+type OrdersRequest struct {
+	Status string `json:"status" form:"status"`
+	// yyyy-MM-dd HH:mm:ss, 东八区
+	StartCreated string `json:"start_created" form:"start_created"`
+	EndCreated   string `json:"end_created" form:"end_created"`
+	PageNo       int    `json:"page_no" form:"page_no" binding:"min=0"`
+	PageSize     int    `json:"page_size" form:"page_size" binding:"min=0,max=100"`
+}
+
+func (r *OrdersRequest) Validate() error {
+	if _, err := common.ParseOptionalTime(r.StartCreated); err != nil {
+		return common.Illegal("start_created", "format")
+	}
+	if _, err := common.ParseOptionalTime(r.EndCreated); err != nil {
+		return common.Illegal("end_created", "format")
+	}
+	return nil
+}
+
+// 平台无关的商品、库存和订单接口, 按店铺配置的平台分发
+type MarketplaceHandler struct {
+	mp marketplace.Provider
+}
+
+func NewMarketplaceHandler(mp marketplace.Provider) *MarketplaceHandler {
+	return &MarketplaceHandler{
+		mp: mp,
+	}
+}
+
+// 商品列表
+func (mh *MarketplaceHandler) Items(c *gin.Context) pkg.Render {
+	var req ItemsRequest
+	if r, ok := common.Bind(c, &req); !ok {
+		return r
+	}
+
+	page, err := mh.mp.ListItems(c.Request.Context(), c.GetString("session"), marketplace.ItemQuery{
+		PageNo:   req.PageNo,
+		PageSize: req.PageSize,
+	})
+	if err != nil {
+		return marketErr(err)
+	}
+	return common.Succ(page)
+}
+
+// 单个商品详情
+func (mh *MarketplaceHandler) Item(c *gin.Context) pkg.Render {
+	var req ItemRequest
+	if r, ok := common.Bind(c, &req); !ok {
+		return r
+	}
+
+	item, err := mh.mp.GetItem(c.Request.Context(), c.GetString("session"), req.ID)
+	if err != nil {
+		return marketErr(err)
+	}
+	return common.Succ(item)
+}
+
+// 批量更新库存
+func (mh *MarketplaceHandler) StockUpdate(c *gin.Context) pkg.Render {
+	var req StockUpdateRequest
+	if r, ok := common.Bind(c, &req); !ok {
+		return r
+	}
+
+	results := mh.mp.UpdateStock(c.Request.Context(), c.GetString("session"), req.Items)
+
+	return common.Succ(map[string]interface{}{"results": results})
+}
+
+// 订单列表
+func (mh *MarketplaceHandler) Orders(c *gin.Context) pkg.Render {
+	var req OrdersRequest
+	if r, ok := common.Bind(c, &req); !ok {
+		return r
+	}
+
+	q := marketplace.OrderQuery{Status: req.Status, PageNo: req.PageNo, PageSize: req.PageSize}
+	q.From, _ = common.ParseOptionalTime(req.StartCreated)
+	q.To, _ = common.ParseOptionalTime(req.EndCreated)
+
+	page, err := mh.mp.ListOrders(c.Request.Context(), c.GetString("session"), q)
+	if err != nil {
+		return marketErr(err)
+	}
+	return common.Succ(page)
+}
+
+func marketErr(err error) pkg.Render {
+	switch err {
+	case marketplace.ErrItemNotFound:
+		return common.ResErr(base.MissingData, base.ErrorMsg(base.MissingData))
+	case marketplace.ErrUnknownPlatform:
+		return common.ResErr(base.DataStatus, base.ErrorMsg(base.DataStatus))
+	}
+	return common.ResErrFrom(err)
+}
@@ -0,0 +1,63 @@
+package oauth
+
+import (
+	"github.com/gin-gonic/gin"
+	"tbTool/api/service/oauth"
+	"tbTool/api/service/shop"
+	"tbTool/api/tools/common"
+	"tbTool/pkg"
+	"tbTool/pkg/gitlab.xfq.com/wpt-api/g-api/errorcode/base"
+)
+
+type AuthorizeHandler struct {
+	ss oauth.SessionService
+}
+
+func NewAuthorizeHandler(ss oauth.SessionService) *AuthorizeHandler {
+	return &AuthorizeHandler{
+		ss: ss,
+	}
+}
+
+// 生成淘宝授权页地址, 由调用方(管理后台)引导卖家浏览器跳转
+func (ah *AuthorizeHandler) Authorize(c *gin.Context) pkg.Render {
+	link, err := ah.ss.AuthorizeURL(c.Request.Context(), common.SellerID(c))
+	if err == oauth.ErrMissingSeller {
+		return common.ResErr(base.ParamError, base.ErrorMsg(base.ParamError)+":seller_id")
+	}
+	if err != nil {
+		return common.ResErrFrom(err)
+	}
+
+	return common.Success(map[string]interface{}{"url": link})
+}
+
+// 授权回调, 用 code 换取 access_token 并保存
+func (ah *AuthorizeHandler) Callback(c *gin.Context) pkg.Render {
+	if e := c.Query("error"); e != "" {
+		return common.ResErr(base.DataStatus, c.Query("error_description"))
+	}
+
+	code, state := c.Query("code"), c.Query("state")
+	if code == "" || state == "" {
+		return common.ResErr(base.ParamError, base.ErrorMsg(base.ParamError)+":code,state")
+	}
+
+	token, err := ah.ss.Exchange(c.Request.Context(), code, state)
+	if err == oauth.ErrInvalidState || err == shop.ErrUnknownShop {
+		return common.ResErr(base.DataStatus, base.ErrorMsg(base.DataStatus))
+	}
+	if err == oauth.ErrSellerMismatch {
+		return common.ResErr(base.DataStatus, base.ErrorMsg(base.DataStatus)+":seller_id")
+	}
+	if err != nil {
+		return common.ResErrFrom(err)
+	}
+
+	return common.Success(map[string]interface{}{
+		"seller_id":        token.SellerID,
+		"taobao_user_id":   token.TaobaoUserID,
+		"taobao_user_nick": token.TaobaoUserNick,
+		"expires_at":       token.ExpiresAt.Unix(),
+	})
+}
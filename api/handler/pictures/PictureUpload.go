@@ -0,0 +1,80 @@
+package pictures
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"io"
+	"io/ioutil"
+	"tbTool/api/service/pictures"
+	"tbTool/api/tools/common"
+	"tbTool/pkg"
+	"tbTool/pkg/gitlab.xfq.com/wpt-api/g-api/errorcode/base"
+)
+
+// multipart/form-data, 图片放在 file 字段
+type PictureUploadRequest struct {
+	CategoryID int64  `json:"category_id" form:"category_id" binding:"min=0"`
+	Title      string `json:"title" form:"title" binding:"max=50"`
+}
+
+type PictureUploadHandler struct {
+	ps pictures.PictureService
+}
+
+func NewPictureUploadHandler(ps pictures.PictureService) *PictureUploadHandler {
+	return &PictureUploadHandler{
+		ps: ps,
+	}
+}
+
+// 上传图片到卖家图片空间, 返回图片id和地址
+func (ph *PictureUploadHandler) PictureUpload(c *gin.Context) pkg.Render {
+	var req PictureUploadRequest
+	if r, ok := common.Bind(c, &req); !ok {
+		return r
+	}
+
+	fh, err := c.FormFile("file")
+	if err != nil {
+		return common.ResParamIllegal(common.Illegal("file", "required").Error())
+	}
+	maxSize := ph.ps.MaxSize()
+	if fh.Size > maxSize {
+		return common.ResParamIllegal(common.Illegal("file", fmt.Sprintf("max=%d", maxSize)).Error())
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return common.ResErrFrom(err)
+	}
+	defer f.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(f, maxSize+1))
+	if err != nil {
+		return common.ResErrFrom(err)
+	}
+
+	picture, err := ph.ps.Upload(c.Request.Context(), c.GetString("session"), pictures.UploadRequest{
+		CategoryID: req.CategoryID,
+		Title:      req.Title,
+		FileName:   fh.Filename,
+		Data:       data,
+	})
+	switch err {
+	case nil:
+	case pictures.ErrEmpty:
+		return common.ResParamIllegal(common.Illegal("file", "required").Error())
+	case pictures.ErrTooLarge:
+		return common.ResParamIllegal(common.Illegal("file", fmt.Sprintf("max=%d", maxSize)).Error())
+	case pictures.ErrUnsupportedType:
+		return common.ResParamIllegal(common.Illegal("file", "oneof=jpg png gif").Error())
+	case pictures.ErrUploadFailed:
+		return common.ResErr(base.DataStatus, base.ErrorMsg(base.DataStatus)+":picture")
+	default:
+		return common.ResErrFrom(err)
+	}
+
+	return common.Success(map[string]interface{}{
+		"picture_id":   picture.PictureID,
+		"picture_path": picture.PicturePath,
+		"picture":      picture,
+	})
+}
@@ -0,0 +1,60 @@
+package refunds
+
+import (
+	"github.com/gin-gonic/gin"
+	"tbTool/api/service/refunds"
+	"tbTool/api/tools/common"
+	"tbTool/pkg"
+)
+
+type RefundsReceiveGetRequest struct {
+	Status string `json:"status" form:"status" binding:"omitempty,oneof=WAIT_SELLER_AGREE WAIT_BUYER_RETURN_GOODS WAIT_SELLER_CONFIRM_GOODS SELLER_REFUSE_BUYER CLOSED SUCCESS"`
+	// yyyy-MM-dd HH:mm:ss
+	StartModified string `json:"start_modified" form:"start_modified"`
+	EndModified   string `json:"end_modified" form:"end_modified"`
+	PageNo        int    `json:"page_no" form:"page_no" binding:"min=0"`
+	PageSize      int    `json:"page_size" form:"page_size" binding:"min=0,max=100"`
+}
+
+func (r *RefundsReceiveGetRequest) Validate() error {
+	if _, err := common.ParseOptionalTime(r.StartModified); err != nil {
+		return common.Illegal("start_modified", "format")
+	}
+	if _, err := common.ParseOptionalTime(r.EndModified); err != nil {
+		return common.Illegal("end_modified", "format")
+	}
+	return nil
+}
+
+type RefundsReceiveGetHandler struct {
+	rs refunds.RefundService
+}
+
+func NewRefundsReceiveGetHandler(rs refunds.RefundService) *RefundsReceiveGetHandler {
+	return &RefundsReceiveGetHandler{
+		rs: rs,
+	}
+}
+
+// 查询卖家收到的退款列表
+func (rh *RefundsReceiveGetHandler) TaoBaoRefundsReceiveGet(c *gin.Context) pkg.Render {
+	var req RefundsReceiveGetRequest
+	if r, ok := common.Bind(c, &req); !ok {
+		return r
+	}
+
+	q := refunds.RefundQuery{
+		Status:   req.Status,
+		PageNo:   req.PageNo,
+		PageSize: req.PageSize,
+	}
+	q.StartModified, _ = common.ParseOptionalTime(req.StartModified)
+	q.EndModified, _ = common.ParseOptionalTime(req.EndModified)
+
+	page, err := rh.rs.RefundsReceiveGet(c.Request.Context(), c.GetString("session"), q)
+	if err != nil {
+		return common.ResErrFrom(err)
+	}
+
+	return common.Succ(page)
+}
@@ -0,0 +1,42 @@
+package trades
+
+import (
+	"github.com/gin-gonic/gin"
+	"tbTool/api/service/trades"
+	"tbTool/api/tools/common"
+	"tbTool/pkg"
+)
+
+type TradeFullinfoGetRequest struct {
+	Tid    int64  `json:"tid" form:"tid" binding:"gt=0"`
+	Fields string `json:"fields" form:"fields"`
+}
+
+func (r *TradeFullinfoGetRequest) Validate() error {
+	return common.CheckList("fields", r.Fields, trades.FullinfoFieldList)
+}
+
+type TradeFullinfoGetHandler struct {
+	ts trades.TradeService
+}
+
+func NewTradeFullinfoGetHandler(ts trades.TradeService) *TradeFullinfoGetHandler {
+	return &TradeFullinfoGetHandler{
+		ts: ts,
+	}
+}
+
+// 获取单笔交易详情
+func (th *TradeFullinfoGetHandler) TaoBaoTradeFullinfoGet(c *gin.Context) pkg.Render {
+	var req TradeFullinfoGetRequest
+	if r, ok := common.Bind(c, &req); !ok {
+		return r
+	}
+
+	trade, err := th.ts.TradeFullinfoGet(c.Request.Context(), c.GetString("session"), req.Tid, req.Fields)
+	if err != nil {
+		return common.ResErrFrom(err)
+	}
+
+	return common.Succ(trade)
+}
@@ -0,0 +1,51 @@
+package trades
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/logger"
+	"tbTool/api/service/shop"
+	"tbTool/api/service/trades"
+	"tbTool/api/tools/common"
+	"tbTool/pkg"
+)
+
+type TradesSyncHandler struct {
+	ts trades.TradeService
+}
+
+func NewTradesSyncHandler(ts trades.TradeService) *TradesSyncHandler {
+	return &TradesSyncHandler{
+		ts: ts,
+	}
+}
+
+// 手动触发当前店铺的订单增量同步, 同步在后台执行
+func (th *TradesSyncHandler) TradesSync(c *gin.Context) pkg.Render {
+	s := c.MustGet("shop").(*shop.Shop)
+
+	// 同步耗时可能超过请求超时, 脱离请求上下文执行
+	ctx := shop.NewContext(context.Background(), s)
+	err := th.ts.StartSyncSold(ctx, func(result *trades.SyncResult, err error) {
+		if err != nil {
+			logger.Errorf("trades sync shop %s error: %v", s.ID, err)
+			return
+		}
+		logger.Infof("trades sync shop %s done: %d trades until %s", s.ID, result.Trades, result.To)
+	})
+	if err == trades.ErrSyncRunning {
+		return common.Success(map[string]interface{}{
+			"shop_id": s.ID,
+			"started": false,
+			"running": true,
+		})
+	}
+	if err != nil {
+		return common.ResErrFrom(err)
+	}
+
+	return common.Success(map[string]interface{}{
+		"shop_id": s.ID,
+		"started": true,
+	})
+}
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"io/ioutil"
+	"net/http"
+	"tbTool/api/service/auth"
+	"tbTool/api/tools/common"
+	"tbTool/pkg/gitlab.xfq.com/wpt-api/g-api/errorcode/base"
+	"time"
+)
+
+// 调用方 HMAC 签名认证, 时间戳限制时钟偏差, nonce 防重放
+func Auth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var body []byte
+		if c.Request.Body != nil {
+			limit := auth.MaxBodySize()
+			data, err := ioutil.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, limit))
+			if err != nil {
+				c.Render(200, common.ResParamIllegal(common.Illegal("body", fmt.Sprintf("max=%d", limit)).Error()))
+				c.Abort()
+				return
+			}
+			// 签名需要读完 body, 还原给后续绑定使用
+			body = data
+			c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+
+		app, err := auth.Verify(c.Request.Context(), auth.Credential{
+			AppID:     c.GetHeader(auth.HeaderAppID),
+			Timestamp: c.GetHeader(auth.HeaderTimestamp),
+			Nonce:     c.GetHeader(auth.HeaderNonce),
+			Signature: c.GetHeader(auth.HeaderSignature),
+		}, c.Request.Method, c.Request.URL.Path, c.Request.URL.RawQuery, body, time.Now())
+
+		var code int
+		switch err {
+		case nil:
+			c.Set("caller", app)
+			c.Next()
+			return
+		case auth.ErrMissingHeader:
+			c.Render(200, common.ResErr(base.ParamError, base.ErrorMsg(base.ParamError)+":"+
+				auth.HeaderAppID+","+auth.HeaderTimestamp+","+auth.HeaderNonce+","+auth.HeaderSignature))
+			c.Abort()
+			return
+		case auth.ErrUnknownApp:
+			code = common.CallerUnknown
+		case auth.ErrExpired:
+			code = common.RequestExpired
+		case auth.ErrSignature:
+			code = common.SignInvalid
+		case auth.ErrReplay:
+			code = common.RequestReplay
+		default:
+			code = base.RedisError
+		}
+		c.Render(200, common.ResErr(int32(code), common.ErrorMsg(code)))
+		c.Abort()
+	}
+}
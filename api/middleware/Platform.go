@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"tbTool/api/service/marketplace"
+	"tbTool/api/service/shop"
+	"tbTool/api/tools/common"
+	"tbTool/pkg/gitlab.xfq.com/wpt-api/g-api/errorcode/base"
+)
+
+// 平台专用接口只接受该平台的店铺, 需在 Shop 之后使用
+func Platform(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		s, ok := shop.FromContext(c.Request.Context())
+		if !ok || marketplace.PlatformOf(s) != name {
+			c.Render(200, common.ResErr(base.DataStatus, base.ErrorMsg(base.DataStatus)+":platform"))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
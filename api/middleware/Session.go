@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"tbTool/api/service/marketplace"
+	"tbTool/api/service/oauth"
+	"tbTool/api/tools/common"
+	"tbTool/pkg/gitlab.xfq.com/wpt-api/g-api/errorcode/base"
+)
+
+// 解析店铺调用凭证, oauth.SessionService 只适用于淘宝店铺, marketplace.Provider 按店铺平台分发
+type SessionResolver interface {
+	Session(ctx context.Context, sellerID string) (string, error)
+}
+
+// 根据店铺配置或调用方传入的卖家id查找授权 session, 写入上下文供 handler 使用
+func Session(ss SessionResolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, err := ss.Session(c.Request.Context(), common.SellerID(c))
+		switch err {
+		case nil:
+		case oauth.ErrMissingSeller:
+			c.Render(200, common.ResErr(base.ParamError, base.ErrorMsg(base.ParamError)+":seller_id"))
+			c.Abort()
+			return
+		case oauth.ErrSellerNotBound:
+			c.Render(200, common.ResParamIllegal("seller_id"))
+			c.Abort()
+			return
+		case marketplace.ErrUnknownPlatform:
+			c.Render(200, common.ResErr(base.DataStatus, base.ErrorMsg(base.DataStatus)+":platform"))
+			c.Abort()
+			return
+		case oauth.ErrNoToken, oauth.ErrTokenExpired:
+			c.Render(200, common.ResErr(base.NotLoginError, base.ErrorMsg(base.NotLoginError)))
+			c.Abort()
+			return
+		default:
+			c.Render(200, common.ResErrFrom(err))
+			c.Abort()
+			return
+		}
+
+		c.Set("session", session)
+		c.Next()
+	}
+}
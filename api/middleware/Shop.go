@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"tbTool/api/service/shop"
+	"tbTool/api/tools/common"
+	"tbTool/pkg/gitlab.xfq.com/wpt-api/g-api/errorcode/base"
+)
+
+// 根据店铺标识选择 app_key/secret/网关, 店铺写入请求上下文供 TOP 调用使用
+func Shop() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader("X-Shop-Id")
+		if id == "" {
+			id = c.Query("shop_id")
+		}
+
+		s, err := shop.Get(id)
+		switch err {
+		case nil:
+		case shop.ErrMissingShop:
+			c.Render(200, common.ResErr(base.ParamError, base.ErrorMsg(base.ParamError)+":shop_id"))
+			c.Abort()
+			return
+		default:
+			c.Render(200, common.ResParamIllegal("shop_id"))
+			c.Abort()
+			return
+		}
+
+		c.Set("shop", s)
+		c.Request = c.Request.WithContext(shop.NewContext(c.Request.Context(), s))
+		c.Next()
+	}
+}
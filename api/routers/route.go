@@ -4,8 +4,19 @@ import (
 	"github.com/gin-gonic/gin"
 	"go.uber.org/dig"
 	"log"
+	"tbTool/api/handler/categories"
+	"tbTool/api/handler/exports"
 	"tbTool/api/handler/items"
+	"tbTool/api/handler/listings"
+	"tbTool/api/handler/logistics"
+	"tbTool/api/handler/marketplace"
+	"tbTool/api/handler/oauth"
+	"tbTool/api/handler/pictures"
+	"tbTool/api/handler/refunds"
+	"tbTool/api/handler/trades"
 	. "tbTool/api/middleware"
+	marketplaceService "tbTool/api/service/marketplace"
+	oauthService "tbTool/api/service/oauth"
 )
 
 func RegisterRouter(c *dig.Container, e *gin.Engine) {
@@ -13,9 +24,143 @@ func RegisterRouter(c *dig.Container, e *gin.Engine) {
 	api := e.Group("/tbApi")
 	api.Use(Sign())
 
+	// 授权回调由淘宝发起, 不带调用方签名和店铺标识, 店铺从 state 中还原
+	authed := api.Group("", Auth())
+	shop := authed.Group("", Shop())
+	// 淘宝专用接口, 其它平台的店铺只能使用 market/* 接口
+	taobao := shop.Group("", Platform(marketplaceService.PlatformTaobao))
+
+	if err := c.Invoke(func(h *oauth.AuthorizeHandler) {
+
+		taobao.GET("oauth/authorize", func(ctx *gin.Context) { ctx.Render(200, h.Authorize(ctx)) })
+		api.GET("oauth/callback", func(ctx *gin.Context) { ctx.Render(200, h.Callback(ctx)) })
+
+	}); err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	var seller *gin.RouterGroup
+	if err := c.Invoke(func(ss oauthService.SessionService) {
+		seller = taobao.Group("", Session(ss))
+	}); err != nil {
+		log.Fatalf("%s", err)
+	}
+
 	if err := c.Invoke(func(h *items.ItemOnSaleGetHandler) {
 
-		api.POST("items/ItemsOnSaleGet", func(ctx *gin.Context) { ctx.Render(200, h.TaoBaoItemsOnSaleGet(ctx)) })
+		seller.POST("items/ItemsOnSaleGet", func(ctx *gin.Context) { ctx.Render(200, h.TaoBaoItemsOnSaleGet(ctx)) })
+
+	}); err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	if err := c.Invoke(func(h *items.ItemSellerGetHandler) {
+
+		seller.POST("items/detail", func(ctx *gin.Context) { ctx.Render(200, h.TaoBaoItemSellerGet(ctx)) })
+
+	}); err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	if err := c.Invoke(func(h *items.ItemUpdateHandler) {
+
+		seller.POST("items/quantity/update", func(ctx *gin.Context) { ctx.Render(200, h.QuantityUpdate(ctx)) })
+		seller.POST("items/price/update", func(ctx *gin.Context) { ctx.Render(200, h.PriceUpdate(ctx)) })
+		seller.POST("items/listing", func(ctx *gin.Context) { ctx.Render(200, h.Listing(ctx)) })
+		seller.POST("items/delisting", func(ctx *gin.Context) { ctx.Render(200, h.Delisting(ctx)) })
+
+	}); err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	if err := c.Invoke(func(h *items.ItemHistoryHandler) {
+
+		taobao.POST("items/history", func(ctx *gin.Context) { ctx.Render(200, h.ItemHistory(ctx)) })
+
+	}); err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	if err := c.Invoke(func(h *listings.ListingsHandler) {
+
+		taobao.POST("listings/schedule", func(ctx *gin.Context) { ctx.Render(200, h.Schedule(ctx)) })
+		taobao.GET("listings/action", func(ctx *gin.Context) { ctx.Render(200, h.Action(ctx)) })
+		taobao.GET("listings/actions", func(ctx *gin.Context) { ctx.Render(200, h.Actions(ctx)) })
+		taobao.POST("listings/cancel", func(ctx *gin.Context) { ctx.Render(200, h.Cancel(ctx)) })
+
+	}); err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	// 凭证由店铺所在平台的实现解析, 不限定淘宝授权
+	if err := c.Invoke(func(h *marketplace.MarketplaceHandler, mp marketplaceService.Provider) {
+
+		market := shop.Group("", Session(mp))
+		market.GET("market/items", func(ctx *gin.Context) { ctx.Render(200, h.Items(ctx)) })
+		market.GET("market/item", func(ctx *gin.Context) { ctx.Render(200, h.Item(ctx)) })
+		market.POST("market/stock/update", func(ctx *gin.Context) { ctx.Render(200, h.StockUpdate(ctx)) })
+		market.GET("market/orders", func(ctx *gin.Context) { ctx.Render(200, h.Orders(ctx)) })
+
+	}); err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	if err := c.Invoke(func(h *pictures.PictureUploadHandler) {
+
+		seller.POST("pictures/upload", func(ctx *gin.Context) { ctx.Render(200, h.PictureUpload(ctx)) })
+
+	}); err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	if err := c.Invoke(func(h *categories.CategoriesHandler) {
+
+		taobao.GET("categories/children", func(ctx *gin.Context) { ctx.Render(200, h.Children(ctx)) })
+		taobao.GET("categories/path", func(ctx *gin.Context) { ctx.Render(200, h.Path(ctx)) })
+		taobao.GET("categories/props", func(ctx *gin.Context) { ctx.Render(200, h.Props(ctx)) })
+		seller.GET("categories/authorized", func(ctx *gin.Context) { ctx.Render(200, h.Authorized(ctx)) })
+
+	}); err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	if err := c.Invoke(func(h *exports.ItemsExportHandler) {
+
+		seller.POST("exports/items", func(ctx *gin.Context) { ctx.Render(200, h.Start(ctx)) })
+		taobao.GET("exports/status", func(ctx *gin.Context) { ctx.Render(200, h.Status(ctx)) })
+		taobao.GET("exports/download", func(ctx *gin.Context) { ctx.Render(200, h.Download(ctx)) })
+
+	}); err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	if err := c.Invoke(func(h *trades.TradesSyncHandler) {
+
+		taobao.POST("trades/sync", func(ctx *gin.Context) { ctx.Render(200, h.TradesSync(ctx)) })
+
+	}); err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	if err := c.Invoke(func(h *trades.TradeFullinfoGetHandler) {
+
+		seller.POST("trades/detail", func(ctx *gin.Context) { ctx.Render(200, h.TaoBaoTradeFullinfoGet(ctx)) })
+
+	}); err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	if err := c.Invoke(func(h *logistics.LogisticsOfflineSendHandler) {
+
+		seller.POST("logistics/offline/send", func(ctx *gin.Context) { ctx.Render(200, h.TaoBaoLogisticsOfflineSend(ctx)) })
+
+	}); err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	if err := c.Invoke(func(h *refunds.RefundsReceiveGetHandler) {
+
+		seller.POST("refunds/receive", func(ctx *gin.Context) { ctx.Render(200, h.TaoBaoRefundsReceiveGet(ctx)) })
 
 	}); err != nil {
 		log.Fatalf("%s", err)
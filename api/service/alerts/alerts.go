@@ -0,0 +1,203 @@
+// 商品告警: 快照完成后按规则评估库存、价格和上下架变化, 通过 webhook 渠道通知
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/grpool"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/logger"
+	"math"
+	"strconv"
+	"tbTool/api/service/items"
+	"tbTool/api/service/snapshots"
+	"time"
+)
+
+const quietPrefix = "tbtool:alerts:quiet:"
+
+// 测试中替换, 避免依赖 redis
+var recentlyDelisted = items.RecentlyDelisted
+
+type Alert struct {
+	Rule      string    `json:"rule"`
+	Type      string    `json:"type"`
+	ShopID    string    `json:"shop_id"`
+	NumIid    int64     `json:"num_iid"`
+	Title     string    `json:"title,omitempty"`
+	OldValue  string    `json:"old_value,omitempty"`
+	NewValue  string    `json:"new_value,omitempty"`
+	Threshold float64   `json:"threshold,omitempty"`
+	Message   string    `json:"message"`
+	FiredAt   time.Time `json:"fired_at"`
+}
+
+// 实现 snapshots.Observer, 规则和渠道从全局配置读取
+type Engine struct{}
+
+func NewEngine() snapshots.Observer {
+	return &Engine{}
+}
+
+func (e *Engine) OnSnapshot(ctx context.Context, shopID string, list []items.Item, changes []snapshots.Change) {
+	now := time.Now()
+	titles := make(map[int64]string, len(list))
+	for _, it := range list {
+		titles[it.NumIid] = it.Title
+	}
+
+	byChannel := map[string]*batch{}
+	for _, r := range Rules() {
+		if !r.matchShop(shopID) {
+			continue
+		}
+		for _, a := range evaluate(ctx, r, shopID, list, changes) {
+			if a.Title == "" {
+				a.Title = titles[a.NumIid]
+			}
+			a.FiredAt = now
+			// 静默期按渠道占用, 一个渠道发送失败不影响其它渠道
+			for _, name := range r.Channels {
+				claim, ok := claimSend(ctx, r, name, a.ShopID, a.NumIid)
+				if !ok {
+					continue
+				}
+				b, ok := byChannel[name]
+				if !ok {
+					b = &batch{}
+					byChannel[name] = b
+				}
+				b.alerts = append(b.alerts, a)
+				if claim != nil {
+					b.claims = append(b.claims, claim)
+				}
+			}
+		}
+	}
+	for name, b := range byChannel {
+		dispatch(name, b)
+	}
+}
+
+// 发往同一渠道的告警及其占用的静默期
+type batch struct {
+	alerts []Alert
+	claims []Claim
+}
+
+// 发送失败时释放静默期, 下次快照可以重新告警
+func (b *batch) release(name string) {
+	for _, l := range b.claims {
+		if err := l.Release(); err != nil {
+			logger.Warnf("alerts: release quiet period for channel %s: %v", name, err)
+		}
+	}
+}
+
+// 规则命中的告警, 同一商品只出一条
+func evaluate(ctx context.Context, r *Rule, shopID string, list []items.Item, changes []snapshots.Change) []Alert {
+	var alerts []Alert
+	switch r.Type {
+	case TypeStockBelow:
+		for _, it := range list {
+			if float64(it.Num) >= r.Threshold {
+				continue
+			}
+			alerts = append(alerts, Alert{
+				Rule:      r.Name,
+				Type:      r.Type,
+				ShopID:    shopID,
+				NumIid:    it.NumIid,
+				Title:     it.Title,
+				NewValue:  strconv.FormatInt(it.Num, 10),
+				Threshold: r.Threshold,
+				Message:   fmt.Sprintf("库存 %d 低于 %v", it.Num, r.Threshold),
+			})
+		}
+	case TypePriceChange:
+		for _, c := range changes {
+			if c.Field != "price" {
+				continue
+			}
+			old, err1 := strconv.ParseFloat(c.OldValue, 64)
+			cur, err2 := strconv.ParseFloat(c.NewValue, 64)
+			if err1 != nil || err2 != nil || old <= 0 {
+				continue
+			}
+			pct := (cur - old) / old * 100
+			if math.Abs(pct) <= r.Threshold {
+				continue
+			}
+			alerts = append(alerts, Alert{
+				Rule:      r.Name,
+				Type:      r.Type,
+				ShopID:    shopID,
+				NumIid:    c.NumIid,
+				OldValue:  c.OldValue,
+				NewValue:  c.NewValue,
+				Threshold: r.Threshold,
+				Message:   fmt.Sprintf("价格 %s → %s (%+.1f%%)", c.OldValue, c.NewValue, pct),
+			})
+		}
+	case TypeUnexpectedDelist:
+		for _, c := range changes {
+			if c.Field != "on_sale" || c.OldValue != "true" || c.NewValue != "false" {
+				continue
+			}
+			// 查询失败按意外下架处理, 宁可多报
+			ours, err := recentlyDelisted(ctx, shopID, c.NumIid)
+			if err != nil {
+				logger.Warnf("alerts: check delisted %s/%d: %v", shopID, c.NumIid, err)
+			}
+			if ours {
+				continue
+			}
+			alerts = append(alerts, Alert{
+				Rule:     r.Name,
+				Type:     r.Type,
+				ShopID:   shopID,
+				NumIid:   c.NumIid,
+				OldValue: c.OldValue,
+				NewValue: c.NewValue,
+				Message:  "商品已不在出售中, 且非本服务下架",
+			})
+		}
+	}
+	return alerts
+}
+
+// 返回 true 时应发送告警: 同一规则同一商品在静默期内每个渠道只通知一次
+// 发送前先占用静默期, 避免多副本重复发送; 返回的 Claim 用于发送失败后释放, 未启用静默期或存储异常时为 nil
+func claimSend(ctx context.Context, r *Rule, channel, shopID string, numIid int64) (Claim, bool) {
+	if r.QuietPeriod <= 0 {
+		return nil, true
+	}
+	key := quietPrefix + r.Name + ":" + channel + ":" + shopID + ":" + strconv.FormatInt(numIid, 10)
+	c, ok, err := currentQuietStore().Claim(ctx, key, r.QuietPeriod)
+	if err != nil {
+		logger.Warnf("alerts: quiet period check for rule %s: %v", r.Name, err)
+		return nil, true
+	}
+	return c, ok
+}
+
+// 通知在后台发送, 不阻塞快照; 未发出的告警释放静默期
+func dispatch(name string, b *batch) {
+	n, ok := channel(name)
+	if !ok {
+		logger.Warnf("alerts: unknown channel %s, %d alerts dropped", name, len(b.alerts))
+		b.release(name)
+		return
+	}
+	err := grpool.Submit(func() {
+		if err := n.Notify(context.Background(), b.alerts); err != nil {
+			logger.Errorf("alerts: notify channel %s: %v", name, err)
+			b.release(name)
+			return
+		}
+		logger.Infof("alerts: notified channel %s with %d alerts", name, len(b.alerts))
+	})
+	if err != nil {
+		logger.Errorf("alerts: submit channel %s: %v", name, err)
+		b.release(name)
+	}
+}
@@ -0,0 +1,158 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"tbTool/api/service/items"
+	"tbTool/api/service/snapshots"
+	"testing"
+	"time"
+)
+
+func TestEvaluate(t *testing.T) {
+	defer func(f func(ctx context.Context, shopID string, numIid int64) (bool, error)) { recentlyDelisted = f }(recentlyDelisted)
+	// 2 由本服务下架, 3 查询失败按意外下架处理
+	recentlyDelisted = func(_ context.Context, _ string, numIid int64) (bool, error) {
+		switch numIid {
+		case 2:
+			return true, nil
+		case 3:
+			return false, errors.New("redis down")
+		}
+		return false, nil
+	}
+
+	list := []items.Item{
+		{NumIid: 1, Num: 3},
+		{NumIid: 2, Num: 5},
+		{NumIid: 3, Num: 10},
+	}
+	tests := []struct {
+		name    string
+		rule    Rule
+		changes []snapshots.Change
+		want    []int64
+	}{
+		{
+			name: "stock below threshold",
+			rule: Rule{Name: "r", Type: TypeStockBelow, Threshold: 5},
+			want: []int64{1},
+		},
+		{
+			name: "price change over percentage",
+			rule: Rule{Name: "r", Type: TypePriceChange, Threshold: 10},
+			changes: []snapshots.Change{
+				{NumIid: 1, Field: "price", OldValue: "10.00", NewValue: "12.00"},
+				{NumIid: 2, Field: "price", OldValue: "10.00", NewValue: "10.50"},
+				{NumIid: 3, Field: "price", OldValue: "10.00", NewValue: "8.00"},
+				{NumIid: 4, Field: "price", OldValue: "0", NewValue: "8.00"},
+				{NumIid: 5, Field: "num", OldValue: "10", NewValue: "100"},
+			},
+			want: []int64{1, 3},
+		},
+		{
+			name: "unexpected delist",
+			rule: Rule{Name: "r", Type: TypeUnexpectedDelist},
+			changes: []snapshots.Change{
+				{NumIid: 1, Field: "on_sale", OldValue: "true", NewValue: "false"},
+				{NumIid: 2, Field: "on_sale", OldValue: "true", NewValue: "false"},
+				{NumIid: 3, Field: "on_sale", OldValue: "true", NewValue: "false"},
+				{NumIid: 4, Field: "on_sale", OldValue: "false", NewValue: "true"},
+			},
+			want: []int64{1, 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := evaluate(context.Background(), &tt.rule, "shop", list, tt.changes)
+			if len(got) != len(tt.want) {
+				t.Fatalf("alerts = %+v, want num_iid %v", got, tt.want)
+			}
+			for i, a := range got {
+				if a.NumIid != tt.want[i] || a.Rule != "r" || a.Type != tt.rule.Type || a.ShopID != "shop" {
+					t.Errorf("alerts[%d] = %+v, want num_iid %d", i, a, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// 记录每次通知, 可指定返回的错误
+type recordNotifier struct {
+	mu    sync.Mutex
+	calls int
+	err   error
+	done  chan struct{}
+}
+
+func newRecordNotifier(err error) *recordNotifier {
+	return &recordNotifier{err: err, done: make(chan struct{}, 8)}
+}
+
+func (n *recordNotifier) Notify(_ context.Context, _ []Alert) error {
+	n.mu.Lock()
+	n.calls++
+	n.mu.Unlock()
+	defer func() { n.done <- struct{}{} }()
+	return n.err
+}
+
+func (n *recordNotifier) wait(t *testing.T) {
+	t.Helper()
+	select {
+	case <-n.done:
+	case <-time.After(time.Second):
+		t.Fatal("notify not called")
+	}
+}
+
+func (n *recordNotifier) count() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.calls
+}
+
+// 发送失败的渠道释放自己的静默期, 下次快照重新告警; 发送成功的渠道仍在静默期内
+func TestFailedDispatchReleasesClaim(t *testing.T) {
+	UseQuietStore(NewMemoryQuietStore())
+	defer UseQuietStore(RedisQuietStore{})
+
+	ok, bad := newRecordNotifier(nil), newRecordNotifier(errors.New("webhook down"))
+	UseChannel("test-ok", ok)
+	UseChannel("test-bad", bad)
+	defer RemoveChannel("test-ok")
+	defer RemoveChannel("test-bad")
+	storeRule(&Rule{Name: "test-low-stock", Type: TypeStockBelow, Threshold: 5, Channels: []string{"test-ok", "test-bad"}, QuietPeriod: time.Hour})
+	defer removeRule("test-low-stock")
+
+	list := []items.Item{{NumIid: 1, Num: 0}}
+	e := NewEngine()
+	e.OnSnapshot(context.Background(), "shop", list, nil)
+	ok.wait(t)
+	bad.wait(t)
+
+	// 释放在 Notify 返回后执行, 等待静默期可重新占用
+	key := quietPrefix + "test-low-stock:test-bad:shop:1"
+	deadline := time.Now().Add(time.Second)
+	for {
+		c, claimed, _ := currentQuietStore().Claim(context.Background(), key, time.Hour)
+		if claimed {
+			c.Release()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("quiet period of failed channel not released")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	e.OnSnapshot(context.Background(), "shop", list, nil)
+	bad.wait(t)
+	if n := bad.count(); n != 2 {
+		t.Errorf("failed channel calls = %d, want 2", n)
+	}
+	if n := ok.count(); n != 1 {
+		t.Errorf("ok channel calls = %d, want 1", n)
+	}
+}
@@ -0,0 +1,179 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/conf"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/logger"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// etcd 中每个通知渠道一个key: business.alert_channels.<name>
+	ChannelWatchPrefix = "business.alert_channels"
+	// 配置文件中渠道列表的key
+	ChannelConfigFileKey = "alert_channels"
+
+	// 通用 JSON webhook
+	ChannelWebhook = "webhook"
+	// 钉钉群机器人
+	ChannelDingTalk = "dingtalk"
+	// 企业微信群机器人
+	ChannelWeCom = "wecom"
+
+	DefaultChannelTimeout = 5 * time.Second
+)
+
+// 通知渠道, 一次评估中同一渠道的告警合并为一次调用
+type Notifier interface {
+	Notify(ctx context.Context, alerts []Alert) error
+}
+
+type Channel struct {
+	Name string
+	Type string
+	URL  string
+	// 签名密钥, 为空时不签名
+	Secret  string
+	Timeout time.Duration
+}
+
+// 按渠道类型构造 Notifier, 新类型通过 RegisterChannelType 接入
+type ChannelBuilder func(ch *Channel) Notifier
+
+var (
+	buildersMu sync.RWMutex
+	builders   = map[string]ChannelBuilder{}
+
+	channelsMu sync.RWMutex
+	channels   = map[string]Notifier{}
+)
+
+func init() {
+	RegisterChannelType(ChannelWebhook, newWebhookNotifier)
+	RegisterChannelType(ChannelDingTalk, newDingTalkNotifier)
+	RegisterChannelType(ChannelWeCom, newWeComNotifier)
+}
+
+func RegisterChannelType(typ string, build ChannelBuilder) {
+	buildersMu.Lock()
+	defer buildersMu.Unlock()
+	builders[strings.ToLower(typ)] = build
+}
+
+// etcd/配置文件中的渠道, timeout 为 time.ParseDuration 格式
+type channelConfig struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	URL     string `json:"url"`
+	Secret  string `json:"secret"`
+	Timeout string `json:"timeout"`
+}
+
+func (cc channelConfig) notifier() (Notifier, error) {
+	ch := &Channel{
+		Name:    cc.Name,
+		Type:    strings.ToLower(cc.Type),
+		URL:     cc.URL,
+		Secret:  cc.Secret,
+		Timeout: DefaultChannelTimeout,
+	}
+	if ch.Name == "" {
+		return nil, fmt.Errorf("alerts: channel name is required")
+	}
+	if ch.URL == "" {
+		return nil, fmt.Errorf("alerts: channel %s url is required", ch.Name)
+	}
+	if cc.Timeout != "" {
+		d, err := time.ParseDuration(cc.Timeout)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("alerts: channel %s invalid timeout %q", ch.Name, cc.Timeout)
+		}
+		ch.Timeout = d
+	}
+
+	buildersMu.RLock()
+	build, ok := builders[ch.Type]
+	buildersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("alerts: channel %s invalid type %q", ch.Name, cc.Type)
+	}
+	return build(ch), nil
+}
+
+// 注册或替换渠道, 测试和自定义渠道可直接传入 Notifier
+func UseChannel(name string, n Notifier) {
+	channelsMu.Lock()
+	defer channelsMu.Unlock()
+	channels[name] = n
+	logger.Infof("alert channel %s stored", name)
+}
+
+func RemoveChannel(name string) {
+	channelsMu.Lock()
+	defer channelsMu.Unlock()
+	delete(channels, name)
+	logger.Infof("alert channel %s deleted", name)
+}
+
+func channel(name string) (Notifier, bool) {
+	channelsMu.RLock()
+	defer channelsMu.RUnlock()
+	n, ok := channels[name]
+	return n, ok
+}
+
+// 从配置文件加载渠道列表
+func LoadChannelsFromConfigFile() error {
+	v := conf.GetFormConfigFile(ChannelConfigFileKey)
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("alerts: marshal %s: %v", ChannelConfigFileKey, err)
+	}
+	var ccs []channelConfig
+	if err := json.Unmarshal(data, &ccs); err != nil {
+		return fmt.Errorf("alerts: unmarshal %s: %v", ChannelConfigFileKey, err)
+	}
+	for _, cc := range ccs {
+		n, err := cc.notifier()
+		if err != nil {
+			return err
+		}
+		UseChannel(cc.Name, n)
+	}
+	return nil
+}
+
+// etcd 渠道监听, value 为单个渠道的 json, 渠道名取 key 的最后一段
+type ChannelEvent struct {
+	Prefix string
+}
+
+func (e *ChannelEvent) GetPrefix() string {
+	return e.Prefix
+}
+
+func (e *ChannelEvent) OnPut(key []byte, value []byte) error {
+	var cc channelConfig
+	if err := json.Unmarshal(value, &cc); err != nil {
+		return fmt.Errorf("alerts: unmarshal %s: %v", key, err)
+	}
+	cc.Name = trimKey(e.Prefix, key)
+	n, err := cc.notifier()
+	if err != nil {
+		return err
+	}
+	UseChannel(cc.Name, n)
+	return nil
+}
+
+func (e *ChannelEvent) OnDelete(key []byte) error {
+	RemoveChannel(trimKey(e.Prefix, key))
+	return nil
+}
@@ -0,0 +1,92 @@
+package alerts
+
+import (
+	"context"
+	dredis "gitlab.xfq.com/tech-lab/dionysus/pkg/redis"
+	"sync"
+	"sync/atomic"
+	"tbTool/api/tools/common"
+	"time"
+)
+
+// 静默期占用, 发送失败后通过 Release 交还
+type Claim interface {
+	Release() error
+}
+
+// 记录静默期, 首次占用返回 true
+type QuietStore interface {
+	Claim(ctx context.Context, key string, ttl time.Duration) (Claim, bool, error)
+}
+
+type quietHolder struct {
+	s QuietStore
+}
+
+var quiets atomic.Value
+
+func init() {
+	quiets.Store(quietHolder{s: RedisQuietStore{}})
+}
+
+// 替换静默期存储, 默认为 redis, 测试时可换为内存实现
+func UseQuietStore(s QuietStore) {
+	quiets.Store(quietHolder{s: s})
+}
+
+func currentQuietStore() QuietStore {
+	return quiets.Load().(quietHolder).s
+}
+
+// 多副本共享的 redis 实现, 释放时只删除自己占用的 key
+type RedisQuietStore struct{}
+
+func (RedisQuietStore) Claim(ctx context.Context, key string, ttl time.Duration) (Claim, bool, error) {
+	rc, err := dredis.GetClient(ctx, common.RedisName)
+	if err != nil {
+		return nil, false, err
+	}
+	l, ok, err := common.TryLock(rc, key, ttl)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return l, true, nil
+}
+
+// 单进程内存实现
+type MemoryQuietStore struct {
+	mu      sync.Mutex
+	claimed map[string]*memoryClaim
+}
+
+type memoryClaim struct {
+	m   *MemoryQuietStore
+	key string
+	exp time.Time
+}
+
+func NewMemoryQuietStore() *MemoryQuietStore {
+	return &MemoryQuietStore{claimed: map[string]*memoryClaim{}}
+}
+
+func (m *MemoryQuietStore) Claim(_ context.Context, key string, ttl time.Duration) (Claim, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if c, ok := m.claimed[key]; ok && time.Now().Before(c.exp) {
+		return nil, false, nil
+	}
+	c := &memoryClaim{m: m, key: key, exp: time.Now().Add(ttl)}
+	m.claimed[key] = c
+	return c, true, nil
+}
+
+func (c *memoryClaim) Release() error {
+	c.m.mu.Lock()
+	defer c.m.mu.Unlock()
+	// 过期后可能已被重新占用
+	if c.m.claimed[c.key] == c {
+		delete(c.m.claimed, c.key)
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package alerts
+
+import (
+	"encoding/json"
+	"fmt"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/conf"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/logger"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// etcd 中每条规则一个key: business.alert_rules.<name>
+	WatchPrefix = "business.alert_rules"
+	// 配置文件中规则列表的key
+	ConfigFileKey = "alert_rules"
+
+	// 库存低于 threshold
+	TypeStockBelow = "stock_below"
+	// 价格变动超过 threshold 百分比
+	TypePriceChange = "price_change"
+	// 非本服务发起的下架
+	TypeUnexpectedDelist = "unexpected_delist"
+
+	// 同一规则同一商品触发后的静默时长
+	DefaultQuietPeriod = time.Hour
+)
+
+type Rule struct {
+	Name      string
+	Type      string
+	Threshold float64
+	// 为空时作用于全部店铺
+	Shops    map[string]bool
+	Channels []string
+	// 触发后静默期内同一商品不再通知
+	QuietPeriod time.Duration
+}
+
+func (r *Rule) matchShop(shopID string) bool {
+	return len(r.Shops) == 0 || r.Shops[shopID]
+}
+
+// etcd/配置文件中的规则, 时间为 time.ParseDuration 格式
+type ruleConfig struct {
+	Name        string   `json:"name"`
+	Type        string   `json:"type"`
+	Threshold   float64  `json:"threshold"`
+	Shops       []string `json:"shops"`
+	Channels    []string `json:"channels"`
+	QuietPeriod string   `json:"quiet_period"`
+}
+
+func (rc ruleConfig) rule() (*Rule, error) {
+	r := &Rule{
+		Name:        rc.Name,
+		Type:        strings.ToLower(rc.Type),
+		Threshold:   rc.Threshold,
+		Channels:    rc.Channels,
+		QuietPeriod: DefaultQuietPeriod,
+	}
+	if r.Name == "" {
+		return nil, fmt.Errorf("alerts: rule name is required")
+	}
+	switch r.Type {
+	case TypeStockBelow, TypePriceChange:
+		if r.Threshold <= 0 {
+			return nil, fmt.Errorf("alerts: rule %s threshold must be positive", r.Name)
+		}
+	case TypeUnexpectedDelist:
+	default:
+		return nil, fmt.Errorf("alerts: rule %s invalid type %q", r.Name, rc.Type)
+	}
+	if len(r.Channels) == 0 {
+		return nil, fmt.Errorf("alerts: rule %s has no channels", r.Name)
+	}
+	if len(rc.Shops) > 0 {
+		r.Shops = make(map[string]bool, len(rc.Shops))
+		for _, id := range rc.Shops {
+			r.Shops[id] = true
+		}
+	}
+	if rc.QuietPeriod != "" {
+		d, err := time.ParseDuration(rc.QuietPeriod)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("alerts: rule %s invalid quiet_period %q", r.Name, rc.QuietPeriod)
+		}
+		r.QuietPeriod = d
+	}
+	return r, nil
+}
+
+var (
+	// 规则名 -> 规则, 变更后重建按名称排序的列表
+	rulesMu sync.Mutex
+	named   = map[string]*Rule{}
+	ruleSet atomic.Value
+)
+
+func init() {
+	ruleSet.Store([]*Rule(nil))
+}
+
+func rebuildRules() {
+	list := make([]*Rule, 0, len(named))
+	for _, r := range named {
+		list = append(list, r)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	ruleSet.Store(list)
+}
+
+func storeRule(r *Rule) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	named[r.Name] = r
+	rebuildRules()
+	logger.Infof("alert rule %s stored: type=%s threshold=%v channels=%v quiet_period=%s",
+		r.Name, r.Type, r.Threshold, r.Channels, r.QuietPeriod)
+}
+
+func removeRule(name string) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	delete(named, name)
+	rebuildRules()
+	logger.Infof("alert rule %s deleted", name)
+}
+
+// 当前全部规则, 按名称排序
+func Rules() []*Rule {
+	return ruleSet.Load().([]*Rule)
+}
+
+// 从配置文件加载规则列表
+func LoadFromConfigFile() error {
+	v := conf.GetFormConfigFile(ConfigFileKey)
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("alerts: marshal %s: %v", ConfigFileKey, err)
+	}
+	var rcs []ruleConfig
+	if err := json.Unmarshal(data, &rcs); err != nil {
+		return fmt.Errorf("alerts: unmarshal %s: %v", ConfigFileKey, err)
+	}
+	for _, rc := range rcs {
+		r, err := rc.rule()
+		if err != nil {
+			return err
+		}
+		storeRule(r)
+	}
+	return nil
+}
+
+// etcd 规则监听, value 为单条规则的 json, 规则名取 key 的最后一段
+type Event struct {
+	Prefix string
+}
+
+func (e *Event) GetPrefix() string {
+	return e.Prefix
+}
+
+func (e *Event) OnPut(key []byte, value []byte) error {
+	var rc ruleConfig
+	if err := json.Unmarshal(value, &rc); err != nil {
+		return fmt.Errorf("alerts: unmarshal %s: %v", key, err)
+	}
+	rc.Name = trimKey(e.Prefix, key)
+	r, err := rc.rule()
+	if err != nil {
+		return err
+	}
+	storeRule(r)
+	return nil
+}
+
+func (e *Event) OnDelete(key []byte) error {
+	removeRule(trimKey(e.Prefix, key))
+	return nil
+}
+
+// business.alert_rules.<name> -> <name>
+func trimKey(prefix string, key []byte) string {
+	return strings.TrimPrefix(strings.TrimPrefix(string(key), prefix), ".")
+}
@@ -0,0 +1,170 @@
+package alerts
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"tbTool/pkg/request"
+	"time"
+)
+
+const (
+	// 通用 webhook 的签名头, 签名为 hex(hmac_sha256(secret, timestamp + "\n" + body))
+	HeaderTimestamp = "X-Tbtool-Timestamp"
+	HeaderSignature = "X-Tbtool-Signature"
+
+	// 机器人消息中最多列出的告警条数, 超出部分只给出数量
+	maxMarkdownAlerts = 20
+
+	webhookRetries = 2
+)
+
+// 通用 JSON webhook, 请求体为 {"alerts": [...]}, 2xx 视为成功
+type webhookNotifier struct {
+	ch *Channel
+}
+
+func newWebhookNotifier(ch *Channel) Notifier {
+	return &webhookNotifier{ch: ch}
+}
+
+func (n *webhookNotifier) Notify(ctx context.Context, alerts []Alert) error {
+	body, err := json.Marshal(map[string]interface{}{"alerts": alerts})
+	if err != nil {
+		return err
+	}
+
+	headers := map[string]string{}
+	if n.ch.Secret != "" {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		mac := hmac.New(sha256.New, []byte(n.ch.Secret))
+		mac.Write([]byte(ts + "\n"))
+		mac.Write(body)
+		headers[HeaderTimestamp] = ts
+		headers[HeaderSignature] = hex.EncodeToString(mac.Sum(nil))
+	}
+
+	resp, data, err := request.Post(n.ch.URL, body, n.ch.Timeout, webhookRetries, request.WithContext(ctx), request.Headers(headers))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("alerts: channel %s http status %d: %s", n.ch.Name, resp.StatusCode, data)
+	}
+	return nil
+}
+
+// 钉钉群机器人, markdown 消息; 配置了加签密钥时按钉钉规则在 url 上附加 timestamp/sign
+type dingTalkNotifier struct {
+	ch *Channel
+}
+
+func newDingTalkNotifier(ch *Channel) Notifier {
+	return &dingTalkNotifier{ch: ch}
+}
+
+func (n *dingTalkNotifier) Notify(ctx context.Context, alerts []Alert) error {
+	title := summary(alerts)
+	body, err := json.Marshal(map[string]interface{}{
+		"msgtype": "markdown",
+		"markdown": map[string]string{
+			"title": title,
+			"text":  "### " + title + "\n\n" + markdown(alerts),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	target := n.ch.URL
+	if n.ch.Secret != "" {
+		ts := strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10)
+		mac := hmac.New(sha256.New, []byte(n.ch.Secret))
+		mac.Write([]byte(ts + "\n" + n.ch.Secret))
+		sign := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+		target = appendQuery(target, url.Values{"timestamp": {ts}, "sign": {sign}})
+	}
+	return n.ch.postRobot(ctx, target, body)
+}
+
+// 企业微信群机器人, markdown 消息
+type weComNotifier struct {
+	ch *Channel
+}
+
+func newWeComNotifier(ch *Channel) Notifier {
+	return &weComNotifier{ch: ch}
+}
+
+func (n *weComNotifier) Notify(ctx context.Context, alerts []Alert) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"msgtype": "markdown",
+		"markdown": map[string]string{
+			"content": "### " + summary(alerts) + "\n" + markdown(alerts),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return n.ch.postRobot(ctx, n.ch.URL, body)
+}
+
+// 钉钉和企业微信机器人均以 errcode 非 0 表示失败
+func (ch *Channel) postRobot(ctx context.Context, target string, body []byte) error {
+	resp, data, err := request.Post(target, body, ch.Timeout, webhookRetries, request.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("alerts: channel %s http status %d: %s", ch.Name, resp.StatusCode, data)
+	}
+	var res struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err := json.Unmarshal(data, &res); err != nil {
+		return fmt.Errorf("alerts: channel %s decode response %q: %v", ch.Name, data, err)
+	}
+	if res.ErrCode != 0 {
+		return fmt.Errorf("alerts: channel %s errcode %d: %s", ch.Name, res.ErrCode, res.ErrMsg)
+	}
+	return nil
+}
+
+func appendQuery(rawUrl string, values url.Values) string {
+	if strings.Contains(rawUrl, "?") {
+		return rawUrl + "&" + values.Encode()
+	}
+	return rawUrl + "?" + values.Encode()
+}
+
+func summary(alerts []Alert) string {
+	return fmt.Sprintf("商品告警 %d 条", len(alerts))
+}
+
+func markdown(alerts []Alert) string {
+	var b strings.Builder
+	for i, a := range alerts {
+		if i == maxMarkdownAlerts {
+			fmt.Fprintf(&b, "- 另有 %d 条未列出\n", len(alerts)-i)
+			break
+		}
+		fmt.Fprintf(&b, "- **%s** 店铺 %s 商品 %s: %s\n", a.Rule, a.ShopID, itemLabel(a), a.Message)
+	}
+	return b.String()
+}
+
+// 下架商品不在本次出售中列表里, 可能没有标题
+func itemLabel(a Alert) string {
+	if a.Title == "" {
+		return strconv.FormatInt(a.NumIid, 10)
+	}
+	return fmt.Sprintf("%s(%d)", a.Title, a.NumIid)
+}
@@ -0,0 +1,60 @@
+package alerts
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 首次请求断开连接后重试, 重试的请求体和签名与首次一致
+func TestWebhookRetryResendsBody(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		hits   int
+		bodies []string
+		header http.Header
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		hits++
+		first := hits == 1
+		bodies = append(bodies, string(body))
+		header = r.Header.Clone()
+		mu.Unlock()
+
+		if first {
+			if conn, _, err := w.(http.Hijacker).Hijack(); err == nil {
+				conn.Close()
+			}
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	n := newWebhookNotifier(&Channel{Name: "test", Type: ChannelWebhook, URL: srv.URL, Secret: "s3cret", Timeout: time.Second})
+	err := n.Notify(context.Background(), []Alert{{Rule: "low-stock", Type: TypeStockBelow, ShopID: "shop", NumIid: 1, Message: "库存 0 低于 5"}})
+	if err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if hits != 2 {
+		t.Fatalf("hits = %d, want 2", hits)
+	}
+	if bodies[1] == "" || bodies[1] != bodies[0] {
+		t.Fatalf("retry body = %q, want %q", bodies[1], bodies[0])
+	}
+
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte(header.Get(HeaderTimestamp) + "\n" + bodies[1]))
+	if want := hex.EncodeToString(mac.Sum(nil)); header.Get(HeaderSignature) != want {
+		t.Errorf("signature = %s, want %s", header.Get(HeaderSignature), want)
+	}
+}
@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/conf"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/logger"
+	"strings"
+	"sync"
+	"tbTool/api/service/top"
+)
+
+const (
+	// etcd 中调用方配置的前缀, 每个调用方一个key: business.callers.<appId>
+	WatchPrefix = "business.callers"
+	// 配置文件中调用方配置的key
+	ConfigFileKey = "callers"
+)
+
+var (
+	ErrUnknownApp = errors.New("auth: unknown or disabled app")
+
+	apps sync.Map
+)
+
+// 调用 /tbApi 的业务方凭证
+type App struct {
+	AppID    string `json:"app_id" mapstructure:"app_id"`
+	Name     string `json:"name" mapstructure:"name"`
+	Secret   string `json:"secret" mapstructure:"secret"`
+	Disabled bool   `json:"disabled" mapstructure:"disabled"`
+}
+
+// 日志输出时隐藏 secret
+func (a *App) String() string {
+	return fmt.Sprintf("{app_id:%s name:%s secret:%s disabled:%t}", a.AppID, a.Name, top.Mask(a.Secret), a.Disabled)
+}
+
+func Store(a *App) error {
+	if a.AppID == "" || a.Secret == "" {
+		return fmt.Errorf("auth: app %q requires app_id and secret", a.AppID)
+	}
+	apps.Store(a.AppID, a)
+	logger.Infof("caller app stored: %s", a)
+	return nil
+}
+
+func Delete(appID string) {
+	apps.Delete(appID)
+	logger.Infof("caller app %s deleted", appID)
+}
+
+func Get(appID string) (*App, error) {
+	v, ok := apps.Load(appID)
+	if !ok || v.(*App).Disabled {
+		return nil, ErrUnknownApp
+	}
+	return v.(*App), nil
+}
+
+// 从配置文件加载调用方
+func LoadFromConfigFile() error {
+	sub := conf.SubFormConfigFile(ConfigFileKey)
+	if sub == nil {
+		return nil
+	}
+	for id := range sub.AllSettings() {
+		a := &App{}
+		if err := sub.UnmarshalKey(id, a); err != nil {
+			return fmt.Errorf("auth: unmarshal %s: %v", id, err)
+		}
+		if a.AppID == "" {
+			a.AppID = id
+		}
+		if err := Store(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// etcd 调用方配置监听, 增删和禁用无需重启
+type Event struct {
+	Prefix string
+}
+
+func (e *Event) GetPrefix() string {
+	return e.Prefix
+}
+
+func (e *Event) OnPut(key []byte, value []byte) error {
+	a := &App{}
+	if err := json.Unmarshal(value, a); err != nil {
+		return fmt.Errorf("auth: json unmarshal %s: %v", string(key), err)
+	}
+	if a.AppID == "" {
+		a.AppID = e.appID(key)
+	}
+	return Store(a)
+}
+
+func (e *Event) OnDelete(key []byte) error {
+	Delete(e.appID(key))
+	return nil
+}
+
+// business.callers.<appId> -> <appId>
+func (e *Event) appID(key []byte) string {
+	return strings.TrimPrefix(strings.TrimPrefix(string(key), e.Prefix), ".")
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"context"
+	dredis "gitlab.xfq.com/tech-lab/dionysus/pkg/redis"
+	"sync"
+	"sync/atomic"
+	"tbTool/api/tools/common"
+	"time"
+)
+
+// 记录已使用的 nonce, 首次使用返回 true
+type NonceStore interface {
+	Use(ctx context.Context, key string, ttl time.Duration) (bool, error)
+}
+
+type nonceHolder struct {
+	s NonceStore
+}
+
+var nonces atomic.Value
+
+func init() {
+	nonces.Store(nonceHolder{s: RedisNonceStore{}})
+}
+
+// 替换 nonce 存储, 默认为 redis, 测试时可换为内存实现
+func UseNonceStore(s NonceStore) {
+	nonces.Store(nonceHolder{s: s})
+}
+
+func currentNonceStore() NonceStore {
+	return nonces.Load().(nonceHolder).s
+}
+
+// 多副本共享的 redis 实现
+type RedisNonceStore struct{}
+
+func (RedisNonceStore) Use(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	rc, err := dredis.GetClient(ctx, common.RedisName)
+	if err != nil {
+		return false, err
+	}
+	return rc.SetNX(key, 1, ttl).Result()
+}
+
+// 单进程内存实现
+type MemoryNonceStore struct {
+	mu   sync.Mutex
+	used map[string]time.Time
+}
+
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{used: map[string]time.Time{}}
+}
+
+func (m *MemoryNonceStore) Use(_ context.Context, key string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for k, exp := range m.used {
+		if now.After(exp) {
+			delete(m.used, k)
+		}
+	}
+	if _, ok := m.used[key]; ok {
+		return false, nil
+	}
+	m.used[key] = now.Add(ttl)
+	return true, nil
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/conf"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HeaderAppID     = "X-App-Id"
+	HeaderTimestamp = "X-Timestamp"
+	HeaderNonce     = "X-Nonce"
+	HeaderSignature = "X-Signature"
+
+	defaultMaxSkew = 5 * time.Minute
+	maxNonceLen    = 64
+	// 需容纳图片上传的 multipart 请求
+	defaultMaxBodySize = 10 << 20
+	nonceKeyPrefix     = "tbtool:auth:nonce:"
+)
+
+var (
+	ErrMissingHeader = errors.New("auth: missing auth header")
+	ErrExpired       = errors.New("auth: timestamp out of allowed skew")
+	ErrReplay        = errors.New("auth: nonce already used")
+	ErrSignature     = errors.New("auth: signature mismatch")
+)
+
+// 待签名串, 各部分以换行分隔:
+// METHOD\nPATH\nRAW_QUERY\nTIMESTAMP\nNONCE\nhex(sha256(body))
+func StringToSign(method, path, rawQuery, timestamp, nonce string, body []byte) string {
+	sum := sha256.Sum256(body)
+	return strings.Join([]string{
+		strings.ToUpper(method), path, rawQuery, timestamp, nonce, hex.EncodeToString(sum[:]),
+	}, "\n")
+}
+
+// hex(HMAC-SHA256(secret, StringToSign))
+func Sign(secret, method, path, rawQuery, timestamp, nonce string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(StringToSign(method, path, rawQuery, timestamp, nonce, body)))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// 请求携带的认证信息
+type Credential struct {
+	AppID     string
+	Timestamp string
+	Nonce     string
+	Signature string
+}
+
+func MaxSkew() time.Duration {
+	if d := conf.GetDurationFormConfigFile("auth.max_skew"); d > 0 {
+		return d
+	}
+	return defaultMaxSkew
+}
+
+// 参与签名的请求体上限, 超出的请求直接拒绝
+func MaxBodySize() int64 {
+	if n := conf.GetSizeInBytesFormConfigFile("auth.max_body_size"); n > 0 {
+		return int64(n)
+	}
+	return defaultMaxBodySize
+}
+
+// 校验时间戳和签名, 通过后再占用 nonce, 签名错误的请求不会消耗 nonce
+func Verify(ctx context.Context, cred Credential, method, path, rawQuery string, body []byte, now time.Time) (*App, error) {
+	if cred.AppID == "" || cred.Timestamp == "" || cred.Nonce == "" || cred.Signature == "" || len(cred.Nonce) > maxNonceLen {
+		return nil, ErrMissingHeader
+	}
+	app, err := Get(cred.AppID)
+	if err != nil {
+		return nil, err
+	}
+
+	ts, err := strconv.ParseInt(cred.Timestamp, 10, 64)
+	if err != nil {
+		return nil, ErrExpired
+	}
+	skew := MaxSkew()
+	if d := now.Sub(time.Unix(ts, 0)); d > skew || d < -skew {
+		return nil, ErrExpired
+	}
+
+	expected := Sign(app.Secret, method, path, rawQuery, cred.Timestamp, cred.Nonce, body)
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(cred.Signature))) {
+		return nil, ErrSignature
+	}
+
+	// 时间戳窗口外的请求已被拒绝, nonce 只需保留两倍窗口
+	ok, err := currentNonceStore().Use(ctx, nonceKeyPrefix+app.AppID+":"+cred.Nonce, 2*skew)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrReplay
+	}
+	return app, nil
+}
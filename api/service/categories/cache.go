@@ -0,0 +1,104 @@
+package categories
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/conf"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/logger"
+	dredis "gitlab.xfq.com/tech-lab/dionysus/pkg/redis"
+	"strconv"
+	"tbTool/api/tools/common"
+	"time"
+)
+
+const (
+	keyPrefix = "tbtool:categories:"
+
+	// 类目树和属性极少变动
+	defaultTreeTTL = 7 * 24 * time.Hour
+	// 授权随卖家资质变化, 过期时间短一些
+	defaultAuthorizeTTL = time.Hour
+)
+
+func treeTTL() time.Duration {
+	if d := conf.GetDurationFormConfigFile("categories.tree_ttl"); d > 0 {
+		return d
+	}
+	return defaultTreeTTL
+}
+
+func authorizeTTL() time.Duration {
+	if d := conf.GetDurationFormConfigFile("categories.authorize_ttl"); d > 0 {
+		return d
+	}
+	return defaultAuthorizeTTL
+}
+
+func childrenKey(parentCid int64) string {
+	return keyPrefix + "children:" + strconv.FormatInt(parentCid, 10)
+}
+
+func catKey(cid int64) string {
+	return keyPrefix + "cat:" + strconv.FormatInt(cid, 10)
+}
+
+func propsKey(cid int64) string {
+	return keyPrefix + "props:" + strconv.FormatInt(cid, 10)
+}
+
+// session 只取摘要
+func authorizeKey(shopID, session string) string {
+	sum := md5.Sum([]byte(session))
+	return keyPrefix + "authorize:" + shopID + ":" + hex.EncodeToString(sum[:])
+}
+
+// 类目数据的 redis 缓存, redis 不可用时直接回源
+type Cache struct{}
+
+func NewCache() *Cache {
+	return &Cache{}
+}
+
+// 命中时解码到 out, 未命中时调用 load 回源并写入缓存
+func (c *Cache) Get(ctx context.Context, key string, ttl time.Duration, out interface{}, load func() (interface{}, error)) error {
+	rc, err := dredis.GetClient(ctx, common.RedisName)
+	if err != nil {
+		logger.Warnf("categories cache get redis client error: %v", err)
+	} else if data, err := rc.Get(key).Bytes(); err == nil {
+		if err := json.Unmarshal(data, out); err == nil {
+			return nil
+		}
+		logger.Warnf("categories cache decode %s error: %v", key, err)
+	}
+
+	v, err := load()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if rc != nil {
+		if err := rc.Set(key, data, ttl).Err(); err != nil {
+			logger.Warnf("categories cache set %s error: %v", key, err)
+		}
+	}
+	return json.Unmarshal(data, out)
+}
+
+func (c *Cache) Set(ctx context.Context, key string, ttl time.Duration, v interface{}) {
+	rc, err := dredis.GetClient(ctx, common.RedisName)
+	if err != nil {
+		return
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	if err := rc.Set(key, data, ttl).Err(); err != nil {
+		logger.Warnf("categories cache set %s error: %v", key, err)
+	}
+}
@@ -0,0 +1,259 @@
+package categories
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"tbTool/api/service/shop"
+	"tbTool/api/service/top"
+)
+
+const (
+	MethodItemCatsGet          = "taobao.itemcats.get"
+	MethodItemCatsAuthorizeGet = "taobao.itemcats.authorize.get"
+	MethodItemPropsGet         = "taobao.itemprops.get"
+
+	ItemCatFields   = "cid,parent_cid,name,is_parent,status,sort_order"
+	AuthorizeFields = "brand.vid,brand.name,brand.prop_name,item_cat.cid,item_cat.name,item_cat.status," +
+		"item_cat.sort_order,item_cat.parent_cid,item_cat.is_parent"
+	ItemPropFields = "pid,name,must,multi,prop_values,is_key_prop,is_sale_prop,is_color_prop,is_enum_prop," +
+		"is_input_prop,is_item_prop,parent_pid,parent_vid,status,sort_order"
+
+	// 根类目的 parent_cid
+	RootCid = 0
+	// 类目路径的最大深度, 防止脏数据成环
+	maxDepth = 10
+)
+
+var (
+	ErrUnknownCategory = errors.New("categories: unknown category")
+	ErrNotLeaf         = errors.New("categories: not a leaf category")
+)
+
+type ItemCat struct {
+	Cid       int64  `json:"cid"`
+	ParentCid int64  `json:"parent_cid"`
+	Name      string `json:"name"`
+	IsParent  bool   `json:"is_parent"`
+	// normal 正常, deleted 已删除
+	Status    string `json:"status,omitempty"`
+	SortOrder int    `json:"sort_order,omitempty"`
+}
+
+type PropValue struct {
+	Vid       int64  `json:"vid"`
+	Name      string `json:"name"`
+	NameAlias string `json:"name_alias,omitempty"`
+	IsParent  bool   `json:"is_parent,omitempty"`
+	SortOrder int    `json:"sort_order,omitempty"`
+}
+
+type ItemProp struct {
+	Pid         int64  `json:"pid"`
+	Name        string `json:"name"`
+	Must        bool   `json:"must"`
+	Multi       bool   `json:"multi"`
+	IsKeyProp   bool   `json:"is_key_prop"`
+	IsSaleProp  bool   `json:"is_sale_prop"`
+	IsColorProp bool   `json:"is_color_prop"`
+	IsEnumProp  bool   `json:"is_enum_prop"`
+	IsInputProp bool   `json:"is_input_prop"`
+	IsItemProp  bool   `json:"is_item_prop"`
+	ParentPid   int64  `json:"parent_pid,omitempty"`
+	ParentVid   int64  `json:"parent_vid,omitempty"`
+	Status      string `json:"status,omitempty"`
+	SortOrder   int    `json:"sort_order,omitempty"`
+	PropValues  struct {
+		PropValue []PropValue `json:"prop_value"`
+	} `json:"prop_values"`
+}
+
+type Brand struct {
+	Vid      int64  `json:"vid"`
+	Name     string `json:"name"`
+	PropName string `json:"prop_name,omitempty"`
+}
+
+// 卖家已授权的类目和品牌
+type SellerAuthorize struct {
+	ItemCats []ItemCat `json:"item_cats"`
+	Brands   []Brand   `json:"brands"`
+}
+
+type ItemCatsGetResponse struct {
+	ItemCats struct {
+		ItemCat []ItemCat `json:"item_cat"`
+	} `json:"item_cats"`
+}
+
+type ItemCatsAuthorizeGetResponse struct {
+	SellerAuthorize struct {
+		ItemCats struct {
+			ItemCat []ItemCat `json:"item_cat"`
+		} `json:"item_cats"`
+		Brands struct {
+			Brand []Brand `json:"brand"`
+		} `json:"brands"`
+	} `json:"seller_authorize"`
+}
+
+type ItemPropsGetResponse struct {
+	ItemProps struct {
+		ItemProp []ItemProp `json:"item_prop"`
+	} `json:"item_props"`
+}
+
+type CategoryService interface {
+	// 子类目, parentCid 为0时返回一级类目
+	Children(ctx context.Context, parentCid int64) ([]ItemCat, error)
+	// 从一级类目到 cid 的完整路径
+	Path(ctx context.Context, cid int64) ([]ItemCat, error)
+	// 叶子类目的属性, requiredOnly 时只返回必填属性
+	Props(ctx context.Context, cid int64, requiredOnly bool) ([]ItemProp, error)
+	// 卖家已授权的类目和品牌
+	Authorized(ctx context.Context, session string) (*SellerAuthorize, error)
+}
+
+type CategoryServiceImpl struct {
+	cli   top.Caller
+	cache *Cache
+}
+
+func NewCategoryServiceImpl(cli top.Caller, cache *Cache) CategoryService {
+	return &CategoryServiceImpl{
+		cli:   cli,
+		cache: cache,
+	}
+}
+
+func (cs *CategoryServiceImpl) Children(ctx context.Context, parentCid int64) ([]ItemCat, error) {
+	var cats []ItemCat
+	err := cs.cache.Get(ctx, childrenKey(parentCid), treeTTL(), &cats, func() (interface{}, error) {
+		params := top.Params{}.
+			Set("fields", ItemCatFields).
+			Set("parent_cid", parentCid)
+		var resp ItemCatsGetResponse
+		if err := cs.cli.Call(ctx, MethodItemCatsGet, params, &resp); err != nil {
+			return nil, err
+		}
+		// 子类目顺带写入单个类目缓存, 解析路径时少回源
+		for _, cat := range resp.ItemCats.ItemCat {
+			cs.cache.Set(ctx, catKey(cat.Cid), treeTTL(), cat)
+		}
+		return resp.ItemCats.ItemCat, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if parentCid != RootCid && len(cats) == 0 {
+		// 区分叶子类目与不存在的类目
+		if _, err := cs.get(ctx, parentCid); err != nil {
+			return nil, err
+		}
+	}
+	return cats, nil
+}
+
+func (cs *CategoryServiceImpl) Path(ctx context.Context, cid int64) ([]ItemCat, error) {
+	var path []ItemCat
+	for next := cid; next != RootCid; {
+		if len(path) >= maxDepth {
+			return nil, ErrUnknownCategory
+		}
+		cat, err := cs.get(ctx, next)
+		if err != nil {
+			return nil, err
+		}
+		path = append(path, *cat)
+		next = cat.ParentCid
+	}
+	if len(path) == 0 {
+		return nil, ErrUnknownCategory
+	}
+
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path, nil
+}
+
+func (cs *CategoryServiceImpl) Props(ctx context.Context, cid int64, requiredOnly bool) ([]ItemProp, error) {
+	cat, err := cs.get(ctx, cid)
+	if err != nil {
+		return nil, err
+	}
+	if cat.IsParent {
+		return nil, ErrNotLeaf
+	}
+
+	var props []ItemProp
+	err = cs.cache.Get(ctx, propsKey(cid), treeTTL(), &props, func() (interface{}, error) {
+		params := top.Params{}.
+			Set("fields", ItemPropFields).
+			Set("cid", cid)
+		var resp ItemPropsGetResponse
+		if err := cs.cli.Call(ctx, MethodItemPropsGet, params, &resp); err != nil {
+			return nil, err
+		}
+		return resp.ItemProps.ItemProp, nil
+	})
+	if err != nil || !requiredOnly {
+		return props, err
+	}
+
+	required := make([]ItemProp, 0, len(props))
+	for _, p := range props {
+		if p.Must {
+			required = append(required, p)
+		}
+	}
+	return required, nil
+}
+
+func (cs *CategoryServiceImpl) Authorized(ctx context.Context, session string) (*SellerAuthorize, error) {
+	s, ok := shop.FromContext(ctx)
+	if !ok {
+		return nil, shop.ErrMissingShop
+	}
+
+	auth := &SellerAuthorize{}
+	err := cs.cache.Get(ctx, authorizeKey(s.ID, session), authorizeTTL(), auth, func() (interface{}, error) {
+		params := top.Params{}.
+			Set("session", session).
+			Set("fields", AuthorizeFields)
+		var resp ItemCatsAuthorizeGetResponse
+		if err := cs.cli.Call(ctx, MethodItemCatsAuthorizeGet, params, &resp); err != nil {
+			return nil, err
+		}
+		return &SellerAuthorize{
+			ItemCats: resp.SellerAuthorize.ItemCats.ItemCat,
+			Brands:   resp.SellerAuthorize.Brands.Brand,
+		}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return auth, nil
+}
+
+// 单个类目, 未命中缓存时按 cids 查询
+func (cs *CategoryServiceImpl) get(ctx context.Context, cid int64) (*ItemCat, error) {
+	cat := &ItemCat{}
+	err := cs.cache.Get(ctx, catKey(cid), treeTTL(), cat, func() (interface{}, error) {
+		params := top.Params{}.
+			Set("fields", ItemCatFields).
+			Set("cids", strconv.FormatInt(cid, 10))
+		var resp ItemCatsGetResponse
+		if err := cs.cli.Call(ctx, MethodItemCatsGet, params, &resp); err != nil {
+			return nil, err
+		}
+		if len(resp.ItemCats.ItemCat) == 0 {
+			return nil, ErrUnknownCategory
+		}
+		return resp.ItemCats.ItemCat[0], nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cat, nil
+}
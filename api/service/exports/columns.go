@@ -0,0 +1,71 @@
+package exports
+
+import (
+	"strconv"
+	"tbTool/api/service/items"
+)
+
+// 导出拉取商品详情的字段, 覆盖全部可选列
+const detailFields = "num_iid,title,price,num,outer_id,approve_status,cid,list_time,modified,sku"
+
+type column struct {
+	name  string
+	title string
+	value func(it *items.ItemDetail, sku *items.Sku) string
+}
+
+// 每个 SKU 一行, 无 SKU 的商品一行且 SKU 列为空
+var columns = []column{
+	{"num_iid", "商品ID", func(it *items.ItemDetail, _ *items.Sku) string { return strconv.FormatInt(it.NumIid, 10) }},
+	{"title", "标题", func(it *items.ItemDetail, _ *items.Sku) string { return it.Title }},
+	{"price", "一口价", func(it *items.ItemDetail, _ *items.Sku) string { return it.Price }},
+	{"num", "库存", func(it *items.ItemDetail, _ *items.Sku) string { return strconv.FormatInt(it.Num, 10) }},
+	{"outer_id", "商家编码", func(it *items.ItemDetail, _ *items.Sku) string { return it.OuterID }},
+	{"approve_status", "状态", func(it *items.ItemDetail, _ *items.Sku) string { return it.ApproveStatus }},
+	{"cid", "类目ID", func(it *items.ItemDetail, _ *items.Sku) string { return strconv.FormatInt(it.Cid, 10) }},
+	{"list_time", "上架时间", func(it *items.ItemDetail, _ *items.Sku) string { return it.ListTime }},
+	{"modified", "修改时间", func(it *items.ItemDetail, _ *items.Sku) string { return it.Modified }},
+	{"sku_id", "SKU ID", skuValue(func(s *items.Sku) string { return strconv.FormatInt(s.SkuID, 10) })},
+	{"sku_properties_name", "销售属性", skuValue(func(s *items.Sku) string { return s.PropertiesName })},
+	{"sku_price", "SKU价格", skuValue(func(s *items.Sku) string { return s.Price })},
+	{"sku_quantity", "SKU库存", skuValue(func(s *items.Sku) string { return strconv.FormatInt(s.Quantity, 10) })},
+	{"sku_outer_id", "SKU商家编码", skuValue(func(s *items.Sku) string { return s.OuterID })},
+}
+
+func skuValue(f func(s *items.Sku) string) func(*items.ItemDetail, *items.Sku) string {
+	return func(_ *items.ItemDetail, sku *items.Sku) string {
+		if sku == nil {
+			return ""
+		}
+		return f(sku)
+	}
+}
+
+// 全部可选列名, 按导出顺序
+func ColumnNames() []string {
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.name
+	}
+	return names
+}
+
+// 按请求顺序选择列, 为空时选择全部
+func selectColumns(names []string) ([]column, error) {
+	if len(names) == 0 {
+		return columns, nil
+	}
+	byName := make(map[string]column, len(columns))
+	for _, c := range columns {
+		byName[c.name] = c
+	}
+	selected := make([]column, 0, len(names))
+	for _, n := range names {
+		c, ok := byName[n]
+		if !ok {
+			return nil, ErrUnknownColumn
+		}
+		selected = append(selected, c)
+	}
+	return selected, nil
+}
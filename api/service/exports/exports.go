@@ -0,0 +1,91 @@
+package exports
+
+import (
+	"context"
+	"errors"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/conf"
+	"io"
+	"tbTool/api/service/items"
+	"tbTool/api/service/oauth"
+	"time"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+
+	StatusPending = "pending"
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+
+	defaultParallelism = 2
+	// 任务状态和导出文件的保留时间
+	retention  = 24 * time.Hour
+	jobTimeout = 30 * time.Minute
+)
+
+var (
+	ErrUnknownJob    = errors.New("exports: unknown job")
+	ErrJobNotDone    = errors.New("exports: job is not done")
+	ErrUnknownFormat = errors.New("exports: unknown format")
+	ErrUnknownColumn = errors.New("exports: unknown column")
+)
+
+type Request struct {
+	Format string
+	// 为空时导出全部列
+	Columns []string
+}
+
+type Job struct {
+	ID         string     `json:"id"`
+	ShopID     string     `json:"shop_id"`
+	Format     string     `json:"format"`
+	Columns    []string   `json:"columns"`
+	Status     string     `json:"status"`
+	Rows       int        `json:"rows"`
+	Size       int64      `json:"size"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// 下载文件名
+func (j *Job) FileName() string {
+	return "items-" + j.ShopID + "-" + j.CreatedAt.In(time.Local).Format("20060102150405") + "." + j.Format
+}
+
+func (j *Job) objectName() string {
+	return j.ID + "." + j.Format
+}
+
+type ExportService interface {
+	// 创建导出任务并在后台执行, 立即返回
+	Start(ctx context.Context, session string, req Request) (*Job, error)
+	// 查询当前店铺的导出任务
+	Status(ctx context.Context, id string) (*Job, error)
+	// 打开已完成任务的导出文件, 调用方负责关闭
+	Open(ctx context.Context, id string) (*Job, io.ReadCloser, error)
+}
+
+type ExportServiceImpl struct {
+	is      items.ItemService
+	ss      oauth.SessionService
+	storage Storage
+	// 限制同时运行的任务数, 排队中的任务保持 pending
+	slots chan struct{}
+}
+
+func NewExportServiceImpl(is items.ItemService, ss oauth.SessionService, storage Storage) ExportService {
+	size := conf.GetIntFormConfigFile("exports.parallelism")
+	if size <= 0 {
+		size = defaultParallelism
+	}
+	return &ExportServiceImpl{
+		is:      is,
+		ss:      ss,
+		storage: storage,
+		slots:   make(chan struct{}, size),
+	}
+}
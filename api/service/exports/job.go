@@ -0,0 +1,273 @@
+package exports
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/go-redis/redis/v7"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/grpool"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/logger"
+	dredis "gitlab.xfq.com/tech-lab/dionysus/pkg/redis"
+	"io"
+	"tbTool/api/service/items"
+	"tbTool/api/service/shop"
+	"tbTool/api/service/top"
+	"tbTool/api/tools/common"
+	"time"
+)
+
+const (
+	jobKeyPrefix = "tbtool:exports:job:"
+	// 每写入多少行更新一次进度
+	progressEvery = 500
+)
+
+func (es *ExportServiceImpl) Start(ctx context.Context, session string, req Request) (*Job, error) {
+	s, ok := shop.FromContext(ctx)
+	if !ok {
+		return nil, shop.ErrMissingShop
+	}
+	if req.Format != FormatCSV && req.Format != FormatXLSX {
+		return nil, ErrUnknownFormat
+	}
+	cols, err := selectColumns(req.Columns)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := dredis.GetClient(ctx, common.RedisName)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+	job := &Job{
+		ID:        id,
+		ShopID:    s.ID,
+		Format:    req.Format,
+		Status:    StatusPending,
+		CreatedAt: time.Now(),
+	}
+	for _, c := range cols {
+		job.Columns = append(job.Columns, c.name)
+	}
+	if err := saveJob(rc, job); err != nil {
+		return nil, err
+	}
+
+	if err := es.storage.Cleanup(time.Now().Add(-retention)); err != nil {
+		logger.Warnf("exports cleanup error: %v", err)
+	}
+
+	// 导出耗时远超请求超时, 脱离请求上下文执行; 配额不足时等待
+	started := *job
+	runCtx := top.WithQuotaMode(shop.NewContext(context.Background(), s), top.QuotaWait)
+	err = grpool.Submit(func() {
+		es.slots <- struct{}{}
+		defer func() { <-es.slots }()
+
+		runCtx, cancel := context.WithTimeout(runCtx, jobTimeout)
+		defer cancel()
+		es.run(runCtx, rc, job, cols, session)
+	})
+	if err != nil {
+		es.finish(rc, job, err)
+		return nil, err
+	}
+	return &started, nil
+}
+
+func (es *ExportServiceImpl) Status(ctx context.Context, id string) (*Job, error) {
+	s, ok := shop.FromContext(ctx)
+	if !ok {
+		return nil, shop.ErrMissingShop
+	}
+	rc, err := dredis.GetClient(ctx, common.RedisName)
+	if err != nil {
+		return nil, err
+	}
+	job, err := loadJob(rc, id)
+	if err != nil {
+		return nil, err
+	}
+	// 不允许跨店铺查询
+	if job.ShopID != s.ID {
+		return nil, ErrUnknownJob
+	}
+	return job, nil
+}
+
+func (es *ExportServiceImpl) Open(ctx context.Context, id string) (*Job, io.ReadCloser, error) {
+	job, err := es.Status(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if job.Status != StatusDone {
+		return nil, nil, ErrJobNotDone
+	}
+	f, err := es.storage.Open(job.objectName())
+	if err != nil {
+		return nil, nil, err
+	}
+	return job, f, nil
+}
+
+func (es *ExportServiceImpl) run(ctx context.Context, rc *redis.Client, job *Job, cols []column, session string) {
+	job.Status = StatusRunning
+	if err := saveJob(rc, job); err != nil {
+		logger.Errorf("exports job %s save error: %v", job.ID, err)
+	}
+
+	err := es.write(ctx, rc, job, cols, session)
+	if err != nil {
+		_ = es.storage.Remove(job.objectName())
+	}
+	es.finish(rc, job, err)
+}
+
+func (es *ExportServiceImpl) finish(rc *redis.Client, job *Job, err error) {
+	now := time.Now()
+	job.FinishedAt = &now
+	job.Status = StatusDone
+	if err != nil {
+		job.Status = StatusFailed
+		job.Error = err.Error()
+		logger.Errorf("exports job %s shop %s failed: %v", job.ID, job.ShopID, err)
+	} else {
+		logger.Infof("exports job %s shop %s done: %d rows, %d bytes", job.ID, job.ShopID, job.Rows, job.Size)
+	}
+	if err := saveJob(rc, job); err != nil {
+		logger.Errorf("exports job %s save error: %v", job.ID, err)
+	}
+}
+
+// 先取全部出售中商品id, 再按批查询详情逐行写出
+func (es *ExportServiceImpl) write(ctx context.Context, rc *redis.Client, job *Job, cols []column, session string) error {
+	page, err := es.is.ItemsOnSaleGet(ctx, session, items.OnSaleQuery{Fields: "num_iid", All: true, Fresh: true})
+	if err != nil {
+		return err
+	}
+	ids := make([]int64, len(page.Items))
+	for i, it := range page.Items {
+		ids[i] = it.NumIid
+	}
+
+	f, err := es.storage.Create(job.objectName())
+	if err != nil {
+		return err
+	}
+	counter := &countingWriter{w: f}
+	w, err := newRowWriter(counter, job.Format)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := writeRows(ctx, es.is, session, w, cols, ids, func(rows int) {
+		job.Rows = rows
+		if rows%progressEvery == 0 {
+			_ = saveJob(rc, job)
+		}
+	}); err != nil {
+		w.Close()
+		f.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	job.Size = counter.n
+	return nil
+}
+
+func writeRows(ctx context.Context, is items.ItemService, session string, w rowWriter, cols []column, ids []int64, progress func(rows int)) error {
+	header := make([]string, len(cols))
+	for i, c := range cols {
+		header[i] = c.title
+	}
+	if err := w.Write(header); err != nil {
+		return err
+	}
+
+	var rows int
+	record := make([]string, len(cols))
+	writeRow := func(it *items.ItemDetail, sku *items.Sku) error {
+		for i, c := range cols {
+			record[i] = c.value(it, sku)
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+		rows++
+		progress(rows)
+		return nil
+	}
+
+	for start := 0; start < len(ids); start += items.MaxSellerListSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		end := start + items.MaxSellerListSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		list, err := is.ItemsSellerListGet(ctx, session, ids[start:end], detailFields)
+		if err != nil {
+			return err
+		}
+		for i := range list {
+			it := &list[i]
+			if len(it.Skus.Sku) == 0 {
+				if err := writeRow(it, nil); err != nil {
+					return err
+				}
+				continue
+			}
+			for j := range it.Skus.Sku {
+				if err := writeRow(it, &it.Skus.Sku[j]); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func saveJob(rc *redis.Client, job *Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return rc.Set(jobKeyPrefix+job.ID, data, retention).Err()
+}
+
+func loadJob(rc *redis.Client, id string) (*Job, error) {
+	data, err := rc.Get(jobKeyPrefix + id).Bytes()
+	if err == redis.Nil {
+		return nil, ErrUnknownJob
+	}
+	if err != nil {
+		return nil, err
+	}
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("exports: decode job %s: %v", id, err)
+	}
+	return &job, nil
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
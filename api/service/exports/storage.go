@@ -0,0 +1,90 @@
+package exports
+
+import (
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/conf"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// 导出文件存储, 本地目录实现可替换为对象存储
+type Storage interface {
+	Create(name string) (io.WriteCloser, error)
+	Open(name string) (io.ReadCloser, error)
+	Remove(name string) error
+	// 删除早于 before 的文件, 保留时间远大于任务超时, 不会误删写入中的文件
+	Cleanup(before time.Time) error
+}
+
+// 多副本部署时目录需要挂载共享存储, 否则只能在生成文件的副本上下载
+type LocalStorage struct {
+	dir string
+}
+
+func NewLocalStorage() Storage {
+	dir := conf.GetStringFormConfigFile("exports.dir")
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "tbtool-exports")
+	}
+	return &LocalStorage{dir: dir}
+}
+
+func (ls *LocalStorage) path(name string) string {
+	return filepath.Join(ls.dir, filepath.Base(name))
+}
+
+// 先写临时文件, 关闭时改名, 未写完的文件不会被下载
+func (ls *LocalStorage) Create(name string) (io.WriteCloser, error) {
+	if err := os.MkdirAll(ls.dir, 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp(ls.dir, filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	return &localFile{File: f, target: ls.path(name)}, nil
+}
+
+func (ls *LocalStorage) Open(name string) (io.ReadCloser, error) {
+	return os.Open(ls.path(name))
+}
+
+func (ls *LocalStorage) Remove(name string) error {
+	err := os.Remove(ls.path(name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (ls *LocalStorage) Cleanup(before time.Time) error {
+	entries, err := os.ReadDir(ls.dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || e.IsDir() || info.ModTime().After(before) {
+			continue
+		}
+		_ = os.Remove(filepath.Join(ls.dir, e.Name()))
+	}
+	return nil
+}
+
+type localFile struct {
+	*os.File
+	target string
+}
+
+func (f *localFile) Close() error {
+	if err := f.File.Close(); err != nil {
+		_ = os.Remove(f.File.Name())
+		return err
+	}
+	return os.Rename(f.File.Name(), f.target)
+}
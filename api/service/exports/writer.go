@@ -0,0 +1,53 @@
+package exports
+
+import (
+	"encoding/csv"
+	"io"
+	"tbTool/pkg/xlsx"
+)
+
+type rowWriter interface {
+	Write(record []string) error
+	Close() error
+}
+
+// excel 打开 utf-8 的 csv 需要 BOM
+const utf8BOM = "\xEF\xBB\xBF"
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (cw *csvWriter) Write(record []string) error {
+	return cw.w.Write(record)
+}
+
+func (cw *csvWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+func newRowWriter(w io.Writer, format string) (rowWriter, error) {
+	switch format {
+	case FormatCSV:
+		if _, err := io.WriteString(w, utf8BOM); err != nil {
+			return nil, err
+		}
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case FormatXLSX:
+		return xlsx.NewWriter(w, "商品")
+	}
+	return nil, ErrUnknownFormat
+}
+
+// 统计写入的字节数
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
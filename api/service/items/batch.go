@@ -0,0 +1,149 @@
+package items
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/grpool"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/logger"
+	"sync"
+	"tbTool/api/service/top"
+	"tbTool/api/tools/common"
+	"tbTool/pkg/gitlab.xfq.com/wpt-api/g-api/errorcode/base"
+)
+
+const (
+	RowSuccess = "success"
+	RowError   = "error"
+	RowSkipped = "skipped"
+
+	// 单次批量最多处理的行数
+	MaxBatchRows = 200
+	// 批量写操作的并发数
+	batchParallelism = 5
+)
+
+// 批量操作逐行结果
+type RowResult struct {
+	NumIid int64  `json:"num_iid"`
+	SkuID  int64  `json:"sku_id,omitempty"`
+	Status string `json:"status"`
+	Code   int32  `json:"code,omitempty"`
+	Msg    string `json:"msg,omitempty"`
+}
+
+func skipped(numIid, skuID int64, code int32, msg string) RowResult {
+	return RowResult{NumIid: numIid, SkuID: skuID, Status: RowSkipped, Code: code, Msg: msg}
+}
+
+// 参数不合法的行直接跳过, 不发起调用
+func illegal(numIid, skuID int64, field string) RowResult {
+	return skipped(numIid, skuID, base.ParamIllegal, fmt.Sprintf(base.ErrorMsg(base.ParamIllegal), field))
+}
+
+func rowResult(numIid, skuID int64, err error) RowResult {
+	if err == nil {
+		return RowResult{NumIid: numIid, SkuID: skuID, Status: RowSuccess}
+	}
+	// 与 common.ResErrFrom 一致, 非业务错误不把内部信息返回给调用方
+	r := RowResult{NumIid: numIid, SkuID: skuID, Status: RowError, Code: base.Error, Msg: base.ErrorMsg(base.Error)}
+	var be common.BizError
+	if errors.As(err, &be) {
+		r.Code, r.Msg = be.BizCode(), be.BizMsg()
+	} else {
+		logger.Errorf("items row %d/%d error: %v", numIid, skuID, err)
+	}
+	return r
+}
+
+type rowKey struct {
+	NumIid int64
+	SkuID  int64
+}
+
+// 有界并发逐行执行, 结果与 keys 下标一一对应; ctx 结束后未执行的行标记为跳过
+func runBatch(ctx context.Context, keys []rowKey, fn func(ctx context.Context, i int) RowResult) []RowResult {
+	results := make([]RowResult, len(keys))
+
+	pool, err := grpool.NewPool(batchParallelism)
+	if err != nil {
+		for i, k := range keys {
+			results[i] = rowResult(k.NumIid, k.SkuID, err)
+		}
+		return results
+	}
+	defer pool.Release()
+
+	var wg sync.WaitGroup
+	for i, k := range keys {
+		i, k := i, k
+		if ctx.Err() != nil {
+			results[i] = skipped(k.NumIid, k.SkuID, base.Error, ctx.Err().Error())
+			continue
+		}
+		wg.Add(1)
+		task := func() {
+			defer wg.Done()
+			if ctx.Err() != nil {
+				results[i] = skipped(k.NumIid, k.SkuID, base.Error, ctx.Err().Error())
+				return
+			}
+			results[i] = fn(ctx, i)
+		}
+		if err := pool.Submit(task); err != nil {
+			wg.Done()
+			results[i] = rowResult(k.NumIid, k.SkuID, err)
+		}
+	}
+	wg.Wait()
+
+	return results
+}
+
+// 构造一行的调用, 参数不合法时返回跳过结果和 false
+type rowCall func(i int) (method string, params top.Params, skip RowResult, ok bool)
+
+// 逐行调用写接口; 店铺配置了批量网关时合并为批量调用, 否则有界并发逐行调用
+func (is *ItemServiceImpl) callRows(ctx context.Context, keys []rowKey, build rowCall) []RowResult {
+	if bc, ok := is.cli.(top.BatchCaller); ok {
+		if results, ok := batchRows(ctx, bc, keys, build); ok {
+			return results
+		}
+	}
+	return runBatch(ctx, keys, func(ctx context.Context, i int) RowResult {
+		method, params, res, ok := build(i)
+		if !ok {
+			return res
+		}
+		return rowResult(keys[i].NumIid, keys[i].SkuID, is.cli.Call(ctx, method, params, nil))
+	})
+}
+
+// 未配置批量网关时返回 false, 此时没有发出任何调用
+func batchRows(ctx context.Context, bc top.BatchCaller, keys []rowKey, build rowCall) ([]RowResult, bool) {
+	results := make([]RowResult, len(keys))
+	calls := make([]top.BatchCall, 0, len(keys))
+	idx := make([]int, 0, len(keys))
+	for i := range keys {
+		method, params, res, ok := build(i)
+		if !ok {
+			results[i] = res
+			continue
+		}
+		calls = append(calls, top.BatchCall{Method: method, Params: params})
+		idx = append(idx, i)
+	}
+	if len(calls) == 0 {
+		return results, true
+	}
+
+	errs := bc.Batch(ctx, calls)
+	if errors.Is(errs[0], top.ErrBatchDisabled) {
+		return nil, false
+	}
+	for n, err := range errs {
+		k := keys[idx[n]]
+		results[idx[n]] = rowResult(k.NumIid, k.SkuID, err)
+	}
+	return results, true
+}
@@ -0,0 +1,48 @@
+package items
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"tbTool/api/service/top"
+	"tbTool/pkg/gitlab.xfq.com/wpt-api/g-api/errorcode/base"
+	"testing"
+	"time"
+)
+
+func TestRowResult(t *testing.T) {
+	te := &top.TopError{Code: 15, Msg: "Remote service error", SubCode: "isv.item-not-exist", SubMsg: "商品不存在"}
+	qe := &top.QuotaError{AppKey: "12345678", Method: MethodItemQuantityUpdate, RetryAfter: time.Second}
+
+	cases := []struct {
+		name   string
+		err    error
+		status string
+		code   int32
+		msg    string
+	}{
+		{name: "success", status: RowSuccess},
+		{name: "top error", err: te, status: RowError, code: te.BizCode(), msg: te.BizMsg()},
+		{name: "wrapped top error", err: fmt.Errorf("call: %w", te), status: RowError, code: te.BizCode(), msg: te.BizMsg()},
+		{name: "quota error", err: qe, status: RowError, code: qe.BizCode(), msg: qe.BizMsg()},
+		{
+			// 传输等内部错误只返回通用提示
+			name:   "internal error",
+			err:    errors.New("top: call taobao.item.quantity.update: dial tcp 10.0.0.1:80: connection refused"),
+			status: RowError,
+			code:   base.Error,
+			msg:    base.ErrorMsg(base.Error),
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := rowResult(1, 2, c.err)
+			if r.NumIid != 1 || r.SkuID != 2 || r.Status != c.status || r.Code != c.code || r.Msg != c.msg {
+				t.Errorf("rowResult = %+v, want status %s code %d msg %q", r, c.status, c.code, c.msg)
+			}
+			if strings.Contains(r.Msg, "12345678") || strings.Contains(r.Msg, "10.0.0.1") {
+				t.Errorf("msg leaks internals: %q", r.Msg)
+			}
+		})
+	}
+}
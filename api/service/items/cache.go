@@ -0,0 +1,215 @@
+package items
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/go-redis/redis/v7"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/conf"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/grpool"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/logger"
+	dredis "gitlab.xfq.com/tech-lab/dionysus/pkg/redis"
+	"sync"
+	"tbTool/api/service/shop"
+	"tbTool/api/tools/common"
+	"time"
+)
+
+const (
+	onSaleKeyPrefix    = "tbtool:items:onsale:"
+	onSaleVerKeyPrefix = "tbtool:items:onsale:ver:"
+	onSaleLockPrefix   = "tbtool:items:onsale:lock:"
+
+	defaultOnSaleTTL      = 5 * time.Minute
+	defaultOnSaleStaleTTL = 30 * time.Minute
+	onSaleLockTTL         = 30 * time.Second
+	// 其他副本正在回源时, 等待其结果的最长时间
+	onSaleLockWait  = 2 * time.Second
+	onSaleLockPoll  = 50 * time.Millisecond
+	onSaleRefreshTO = 30 * time.Second
+)
+
+type onSaleEntry struct {
+	Page      *ItemsOnSalePage `json:"page"`
+	FetchedAt time.Time        `json:"fetched_at"`
+}
+
+type onSaleCall struct {
+	wg   sync.WaitGroup
+	page *ItemsOnSalePage
+	err  error
+}
+
+// 出售中商品列表缓存
+// 1.超过 ttl 未超过 ttl+stale_ttl 的数据直接返回, 同时后台刷新
+// 2.同一 key 的并发未命中只回源一次, 本进程内合并, 跨副本用 redis 锁
+// 3.按店铺失效: 店铺版本号递增后旧 key 不再命中, 由过期时间清理
+type OnSaleCache struct {
+	mu    sync.Mutex
+	calls map[string]*onSaleCall
+}
+
+func NewOnSaleCache() *OnSaleCache {
+	return &OnSaleCache{calls: map[string]*onSaleCall{}}
+}
+
+func onSaleTTL() (time.Duration, time.Duration) {
+	ttl := conf.GetDurationFormConfigFile("items.onsale_cache_ttl")
+	if ttl == 0 {
+		ttl = defaultOnSaleTTL
+	}
+	stale := conf.GetDurationFormConfigFile("items.onsale_stale_ttl")
+	if stale <= 0 {
+		stale = defaultOnSaleStaleTTL
+	}
+	return ttl, stale
+}
+
+// ttl 配置为负数时关闭缓存; 无店铺或 redis 不可用时直接回源
+func (c *OnSaleCache) Get(ctx context.Context, session string, q OnSaleQuery, load func(ctx context.Context) (*ItemsOnSalePage, error)) (*ItemsOnSalePage, error) {
+	ttl, stale := onSaleTTL()
+	s, ok := shop.FromContext(ctx)
+	if ttl < 0 || !ok || q.Fresh {
+		return load(ctx)
+	}
+	rc, err := dredis.GetClient(ctx, common.RedisName)
+	if err != nil {
+		logger.Errorf("items onsale cache redis error: %v", err)
+		return load(ctx)
+	}
+
+	ver, err := rc.Get(onSaleVerKeyPrefix + s.ID).Result()
+	if err != nil && err != redis.Nil {
+		logger.Errorf("items onsale cache version %s error: %v", s.ID, err)
+		return load(ctx)
+	}
+	key := onSaleKey(s.ID, ver, session, q)
+
+	if entry, ok := getOnSale(rc, key); ok {
+		if time.Since(entry.FetchedAt) > ttl {
+			c.refresh(rc, s, key, ttl+stale, load)
+		}
+		return entry.Page, nil
+	}
+
+	return c.collapse(key, func() (*ItemsOnSalePage, error) {
+		lock, locked, err := common.TryLock(rc, onSaleLockPrefix+key, onSaleLockTTL)
+		if err == nil && !locked {
+			if entry, ok := waitOnSale(ctx, rc, key); ok {
+				return entry.Page, nil
+			}
+		}
+		if locked {
+			defer lock.Release()
+		}
+		return loadOnSale(ctx, rc, key, ttl+stale, load)
+	})
+}
+
+// 写接口或 TMC 商品变更后失效店铺的全部缓存
+func (c *OnSaleCache) Invalidate(ctx context.Context, shopID string) {
+	rc, err := dredis.GetClient(ctx, common.RedisName)
+	if err != nil {
+		logger.Errorf("items onsale cache invalidate %s error: %v", shopID, err)
+		return
+	}
+	if err := rc.Incr(onSaleVerKeyPrefix + shopID).Err(); err != nil {
+		logger.Errorf("items onsale cache invalidate %s error: %v", shopID, err)
+	}
+}
+
+// 后台刷新不受请求 ctx 取消影响, 同一 key 只有一个副本在刷新
+func (c *OnSaleCache) refresh(rc *redis.Client, s *shop.Shop, key string, expire time.Duration, load func(ctx context.Context) (*ItemsOnSalePage, error)) {
+	lock, locked, err := common.TryLock(rc, onSaleLockPrefix+key, onSaleLockTTL)
+	if err != nil || !locked {
+		return
+	}
+	err = grpool.Submit(func() {
+		defer lock.Release()
+		ctx, cancel := context.WithTimeout(shop.NewContext(context.Background(), s), onSaleRefreshTO)
+		defer cancel()
+		_, _ = c.collapse(key, func() (*ItemsOnSalePage, error) {
+			return loadOnSale(ctx, rc, key, expire, load)
+		})
+	})
+	if err != nil {
+		lock.Release()
+	}
+}
+
+func (c *OnSaleCache) collapse(key string, fn func() (*ItemsOnSalePage, error)) (*ItemsOnSalePage, error) {
+	c.mu.Lock()
+	if call, ok := c.calls[key]; ok {
+		c.mu.Unlock()
+		call.wg.Wait()
+		return call.page, call.err
+	}
+	call := &onSaleCall{}
+	call.wg.Add(1)
+	c.calls[key] = call
+	c.mu.Unlock()
+
+	call.page, call.err = fn()
+	call.wg.Done()
+
+	c.mu.Lock()
+	delete(c.calls, key)
+	c.mu.Unlock()
+	return call.page, call.err
+}
+
+func loadOnSale(ctx context.Context, rc *redis.Client, key string, expire time.Duration, load func(ctx context.Context) (*ItemsOnSalePage, error)) (*ItemsOnSalePage, error) {
+	page, err := load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(onSaleEntry{Page: page, FetchedAt: time.Now()})
+	if err == nil {
+		err = rc.Set(key, data, expire).Err()
+	}
+	if err != nil {
+		logger.Errorf("items onsale cache set %s error: %v", key, err)
+	}
+	return page, nil
+}
+
+func getOnSale(rc *redis.Client, key string) (*onSaleEntry, bool) {
+	data, err := rc.Get(key).Bytes()
+	if err != nil {
+		if err != redis.Nil {
+			logger.Errorf("items onsale cache get %s error: %v", key, err)
+		}
+		return nil, false
+	}
+	var entry onSaleEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Page == nil {
+		return nil, false
+	}
+	return &entry, true
+}
+
+func waitOnSale(ctx context.Context, rc *redis.Client, key string) (*onSaleEntry, bool) {
+	deadline := time.Now().Add(onSaleLockWait)
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return nil, false
+		case <-time.After(onSaleLockPoll):
+		}
+		if entry, ok := getOnSale(rc, key); ok {
+			return entry, true
+		}
+	}
+	return nil, false
+}
+
+// session 只取摘要, 不同卖家的列表互不可见
+func onSaleKey(shopID, ver, session string, q OnSaleQuery) string {
+	if ver == "" {
+		ver = "0"
+	}
+	sum := md5.Sum([]byte(fmt.Sprintf("%s|%s|%d|%d|%t", session, q.Fields, q.PageNo, q.PageSize, q.All)))
+	return onSaleKeyPrefix + shopID + ":" + ver + ":" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,56 @@
+package items
+
+import (
+	"context"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/logger"
+	dredis "gitlab.xfq.com/tech-lab/dionysus/pkg/redis"
+	"strconv"
+	"tbTool/api/service/shop"
+	"tbTool/api/tools/common"
+	"time"
+)
+
+const (
+	delistedPrefix = "tbtool:items:delisted:"
+	// 覆盖快照间隔即可, 过期后的下架视为非本服务发起
+	delistedTTL = 24 * time.Hour
+)
+
+func delistedKey(shopID string, numIid int64) string {
+	return delistedPrefix + shopID + ":" + strconv.FormatInt(numIid, 10)
+}
+
+// 记录本服务成功下架的商品, 供告警区分主动下架和意外下架
+func markDelisted(ctx context.Context, results []RowResult) []RowResult {
+	s, ok := shop.FromContext(ctx)
+	if !ok {
+		return results
+	}
+	rc, err := dredis.GetClient(ctx, common.RedisName)
+	if err != nil {
+		logger.Warnf("items: mark delisted for shop %s: %v", s.ID, err)
+		return results
+	}
+	for _, r := range results {
+		if r.Status != RowSuccess {
+			continue
+		}
+		if err := rc.Set(delistedKey(s.ID, r.NumIid), 1, delistedTTL).Err(); err != nil {
+			logger.Warnf("items: mark delisted %s/%d: %v", s.ID, r.NumIid, err)
+		}
+	}
+	return results
+}
+
+// 商品最近是否由本服务下架
+func RecentlyDelisted(ctx context.Context, shopID string, numIid int64) (bool, error) {
+	rc, err := dredis.GetClient(ctx, common.RedisName)
+	if err != nil {
+		return false, err
+	}
+	n, err := rc.Exists(delistedKey(shopID, numIid)).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
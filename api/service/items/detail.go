@@ -0,0 +1,136 @@
+package items
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"tbTool/api/service/top"
+)
+
+const (
+	MethodItemSellerGet      = "taobao.item.seller.get"
+	MethodItemsSellerListGet = "taobao.items.seller.list.get"
+
+	// taobao.items.seller.list.get 单次最多查询的商品数
+	MaxSellerListSize = 20
+
+	DefaultDetailFields = "num_iid,title,nick,price,num,outer_id,cid,seller_cids,props,props_name,property_alias," +
+		"input_pids,input_str,desc,pic_url,item_img,prop_img,sku,approve_status,list_time,delist_time,created,modified," +
+		"type,stuff_status,location"
+)
+
+// 商品详情可选字段
+var DetailFieldList = strings.Split(DefaultDetailFields, ",")
+
+type ItemImg struct {
+	ID       int64  `json:"id"`
+	URL      string `json:"url"`
+	Position int    `json:"position"`
+}
+
+type PropImg struct {
+	ID         int64  `json:"id"`
+	URL        string `json:"url"`
+	Properties string `json:"properties"`
+	Position   int    `json:"position"`
+}
+
+type Sku struct {
+	SkuID          int64  `json:"sku_id"`
+	Properties     string `json:"properties,omitempty"`
+	PropertiesName string `json:"properties_name,omitempty"`
+	Quantity       int64  `json:"quantity"`
+	Price          string `json:"price,omitempty"`
+	OuterID        string `json:"outer_id,omitempty"`
+	Created        string `json:"created,omitempty"`
+	Modified       string `json:"modified,omitempty"`
+}
+
+type Location struct {
+	State string `json:"state,omitempty"`
+	City  string `json:"city,omitempty"`
+}
+
+type ItemDetail struct {
+	Item
+	Nick          string    `json:"nick,omitempty"`
+	Type          string    `json:"type,omitempty"`
+	StuffStatus   string    `json:"stuff_status,omitempty"`
+	SellerCids    string    `json:"seller_cids,omitempty"`
+	Props         string    `json:"props,omitempty"`
+	PropsName     string    `json:"props_name,omitempty"`
+	PropertyAlias string    `json:"property_alias,omitempty"`
+	InputPids     string    `json:"input_pids,omitempty"`
+	InputStr      string    `json:"input_str,omitempty"`
+	Desc          string    `json:"desc,omitempty"`
+	Created       string    `json:"created,omitempty"`
+	Location      *Location `json:"location,omitempty"`
+	ItemImgs      struct {
+		ItemImg []ItemImg `json:"item_img"`
+	} `json:"item_imgs"`
+	PropImgs struct {
+		PropImg []PropImg `json:"prop_img"`
+	} `json:"prop_imgs"`
+	Skus struct {
+		Sku []Sku `json:"sku"`
+	} `json:"skus"`
+}
+
+type ItemSellerGetResponse struct {
+	Item      *ItemDetail `json:"item"`
+	RequestID string      `json:"request_id"`
+}
+
+type ItemsSellerListGetResponse struct {
+	Items struct {
+		Item []ItemDetail `json:"item"`
+	} `json:"items"`
+	RequestID string `json:"request_id"`
+}
+
+// 获取单个商品的详细信息(卖家视角, 含SKU/属性/图片/描述)
+func (is *ItemServiceImpl) ItemSellerGet(ctx context.Context, session string, numIid int64, fields string) (*ItemDetail, error) {
+	if fields == "" {
+		fields = DefaultDetailFields
+	}
+	params := top.Params{}.
+		Set("session", session).
+		Set("fields", fields).
+		Set("num_iid", numIid)
+
+	var resp ItemSellerGetResponse
+	if err := is.cli.Call(ctx, MethodItemSellerGet, params, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Item, nil
+}
+
+// 批量获取商品详情, 超过 MaxSellerListSize 个时分批查询
+func (is *ItemServiceImpl) ItemsSellerListGet(ctx context.Context, session string, numIids []int64, fields string) ([]ItemDetail, error) {
+	if fields == "" {
+		fields = DefaultDetailFields
+	}
+
+	list := make([]ItemDetail, 0, len(numIids))
+	for start := 0; start < len(numIids); start += MaxSellerListSize {
+		end := start + MaxSellerListSize
+		if end > len(numIids) {
+			end = len(numIids)
+		}
+		ids := make([]string, 0, end-start)
+		for _, id := range numIids[start:end] {
+			ids = append(ids, strconv.FormatInt(id, 10))
+		}
+		params := top.Params{}.
+			Set("session", session).
+			Set("fields", fields).
+			Set("num_iids", ids)
+
+		var resp ItemsSellerListGetResponse
+		if err := is.cli.Call(ctx, MethodItemsSellerListGet, params, &resp); err != nil {
+			return nil, err
+		}
+		list = append(list, resp.Items.Item...)
+	}
+	return list, nil
+}
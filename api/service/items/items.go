@@ -1,58 +1,212 @@
 package items
 
 import (
-	"bytes"
-	"time"
+	"context"
+	"errors"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/grpool"
+	"sync"
+	"tbTool/api/service/top"
 )
 
 const (
-	AppKey      = "21593345"
-	GatewayLink = "http://openapi.cdshoes.cn/OpenApi/Call/Dev13074885409"
+	MethodItemsOnSaleGet = "taobao.items.onsale.get"
+
+	DefaultOnSaleFields = "num_iid,title,price"
+
+	DefaultPageSize = 40
+	MaxPageSize     = 200
+	// 全量拉取时的并发页数
+	allPagesParallelism = 5
+
+	// approve_status 取值: 出售中/库中
+	ApproveStatusOnSale  = "onsale"
+	ApproveStatusInStock = "instock"
 )
 
+var ErrSkuNotFound = errors.New("items: sku not found")
+
+// 出售中列表可选字段, 与 Item 一致
+var OnSaleFieldList = []string{
+	"num_iid", "title", "price", "num", "outer_id", "pic_url", "cid",
+	"approve_status", "list_time", "delist_time", "modified",
+}
+
+type Item struct {
+	NumIid        int64  `json:"num_iid"`
+	Title         string `json:"title,omitempty"`
+	Price         string `json:"price,omitempty"`
+	Num           int64  `json:"num,omitempty"`
+	OuterID       string `json:"outer_id,omitempty"`
+	PicURL        string `json:"pic_url,omitempty"`
+	Cid           int64  `json:"cid,omitempty"`
+	ApproveStatus string `json:"approve_status,omitempty"`
+	ListTime      string `json:"list_time,omitempty"`
+	DelistTime    string `json:"delist_time,omitempty"`
+	Modified      string `json:"modified,omitempty"`
+}
+
+type ItemsOnSaleGetResponse struct {
+	Items struct {
+		Item []Item `json:"item"`
+	} `json:"items"`
+	TotalResults int    `json:"total_results"`
+	RequestID    string `json:"request_id"`
+}
+
+type OnSaleQuery struct {
+	Fields   string
+	PageNo   int
+	PageSize int
+	// 遍历 total_results 拉取全部分页
+	All bool
+	// 跳过缓存直接回源, 结果也不写入缓存
+	Fresh bool
+}
+
+type ItemsOnSalePage struct {
+	Items        []Item `json:"items"`
+	PageNo       int    `json:"page_no"`
+	PageSize     int    `json:"page_size"`
+	TotalResults int    `json:"total_results"`
+	TotalPages   int    `json:"total_pages"`
+	HasNext      bool   `json:"has_next"`
+}
+
 type ItemService interface {
-	GetTaoBaoItemsUrl(method, sign, session string, requestMap map[string]string) (itemUrl string)
+	ItemsOnSaleGet(ctx context.Context, session string, q OnSaleQuery) (*ItemsOnSalePage, error)
+	ItemSellerGet(ctx context.Context, session string, numIid int64, fields string) (*ItemDetail, error)
+	ItemsSellerListGet(ctx context.Context, session string, numIids []int64, fields string) ([]ItemDetail, error)
+	UpdateQuantity(ctx context.Context, session string, rows []QuantityUpdate) []RowResult
+	UpdatePrice(ctx context.Context, session string, rows []PriceUpdate) []RowResult
+	Listing(ctx context.Context, session string, rows []ListingUpdate) []RowResult
+	Delisting(ctx context.Context, session string, numIids []int64) []RowResult
 }
 
-type ItemServiceImpl struct{}
+type ItemServiceImpl struct {
+	cli   top.Caller
+	cache *OnSaleCache
+}
 
-func NewItemServiceImpl() ItemService {
-	return &ItemServiceImpl{}
+func NewItemServiceImpl(cli top.Caller, cache *OnSaleCache) ItemService {
+	return &ItemServiceImpl{
+		cli:   cli,
+		cache: cache,
+	}
 }
 
-//淘宝商品请求生成接口
-func (is *ItemServiceImpl) GetTaoBaoItemsUrl(method, sign, session string ,requestMap map[string]string) (itemUrl string) {
-	var buff bytes.Buffer
-	buff.WriteString(GatewayLink)
-	buff.WriteString("&app_key=")
-	buff.WriteString(AppKey)
-	buff.WriteString("&method=")
-	buff.WriteString(method)
-	buff.WriteString("&v=2.0")
-	buff.WriteString("&sign=")
-	buff.WriteString(sign)
-	buff.WriteString("&timestamp=")
-	buff.WriteString(time.Now().Format("2006-01-02 15:04:05"))
-	buff.WriteString("&partner_id=top-apitools")
-	buff.WriteString("&session=")
-	buff.WriteString(session)
-	buff.WriteString(requestObjectJson(requestMap))
-	buff.WriteString("&format=json&sign_method=md5")
+func (q OnSaleQuery) normalize() OnSaleQuery {
+	if q.PageSize <= 0 {
+		q.PageSize = DefaultPageSize
+	}
+	if q.PageSize > MaxPageSize || q.All {
+		q.PageSize = MaxPageSize
+	}
+	if q.PageNo <= 0 || q.All {
+		q.PageNo = 1
+	}
+	return q
+}
+
+// 获取当前会话用户出售中的商品列表, 优先读缓存
+func (is *ItemServiceImpl) ItemsOnSaleGet(ctx context.Context, session string, q OnSaleQuery) (*ItemsOnSalePage, error) {
+	q = q.normalize()
+	return is.cache.Get(ctx, session, q, func(ctx context.Context) (*ItemsOnSalePage, error) {
+		return is.itemsOnSaleGet(ctx, session, q)
+	})
+}
+
+func (is *ItemServiceImpl) itemsOnSaleGet(ctx context.Context, session string, q OnSaleQuery) (*ItemsOnSalePage, error) {
+	first, err := is.itemsOnSalePage(ctx, session, q.Fields, q.PageNo, q.PageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &ItemsOnSalePage{
+		Items:        first.Items.Item,
+		PageNo:       q.PageNo,
+		PageSize:     q.PageSize,
+		TotalResults: first.TotalResults,
+		TotalPages:   (first.TotalResults + q.PageSize - 1) / q.PageSize,
+	}
+	page.HasNext = page.PageNo < page.TotalPages
+
+	if !q.All || page.TotalPages <= 1 {
+		return page, nil
+	}
 
+	rest, err := is.itemsOnSaleRest(ctx, session, q.Fields, page.TotalPages, q.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	for _, items := range rest {
+		page.Items = append(page.Items, items...)
+	}
+	page.PageSize = len(page.Items)
+	page.TotalPages = 1
+	page.HasNext = false
 
-	return buff.String()
+	return page, nil
 }
 
-//参数拼接
-func requestObjectJson(requestMap map[string]string) string {
-	var buff bytes.Buffer
+func (is *ItemServiceImpl) itemsOnSalePage(ctx context.Context, session, fields string, pageNo, pageSize int) (*ItemsOnSaleGetResponse, error) {
+	params := top.Params{}.
+		Set("session", session).
+		Set("fields", fields).
+		Set("page_no", pageNo).
+		Set("page_size", pageSize)
+
+	var resp ItemsOnSaleGetResponse
+	if err := is.cli.Call(ctx, MethodItemsOnSaleGet, params, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
 
-	buff.WriteString("&")
-	for k,v :=range requestMap {
-		buff.WriteString(k+"=")
-		buff.WriteString(v)
-		buff.WriteString("&")
+// 并发拉取第2页到最后一页, 结果按页码顺序返回
+func (is *ItemServiceImpl) itemsOnSaleRest(ctx context.Context, session, fields string, totalPages, pageSize int) ([][]Item, error) {
+	pool, err := grpool.NewPool(allPagesParallelism)
+	if err != nil {
+		return nil, err
 	}
+	defer pool.Release()
 
-	return buff.String()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+		pages    = make([][]Item, totalPages-1)
+	)
+	for pageNo := 2; pageNo <= totalPages; pageNo++ {
+		pageNo := pageNo
+		wg.Add(1)
+		task := func() {
+			defer wg.Done()
+			resp, err := is.itemsOnSalePage(ctx, session, fields, pageNo, pageSize)
+			if err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+				return
+			}
+			pages[pageNo-2] = resp.Items.Item
+		}
+		if err := pool.Submit(task); err != nil {
+			wg.Done()
+			once.Do(func() {
+				firstErr = err
+				cancel()
+			})
+			break
+		}
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return pages, nil
 }
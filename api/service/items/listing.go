@@ -0,0 +1,58 @@
+package items
+
+import (
+	"context"
+	"tbTool/api/service/top"
+)
+
+const (
+	MethodItemUpdateListing   = "taobao.item.update.listing"
+	MethodItemUpdateDelisting = "taobao.item.update.delisting"
+)
+
+type ListingUpdate struct {
+	NumIid int64 `json:"num_iid"`
+	// 上架数量, 需大于0且不超过库存
+	Num int64 `json:"num"`
+}
+
+// 批量上架, 逐行返回结果
+func (is *ItemServiceImpl) Listing(ctx context.Context, session string, rows []ListingUpdate) []RowResult {
+	keys := make([]rowKey, len(rows))
+	for i, r := range rows {
+		keys[i] = rowKey{NumIid: r.NumIid}
+	}
+
+	return is.invalidateOnSale(ctx, is.callRows(ctx, keys, func(i int) (string, top.Params, RowResult, bool) {
+		r := rows[i]
+		switch {
+		case r.NumIid <= 0:
+			return "", nil, illegal(r.NumIid, 0, "num_iid"), false
+		case r.Num <= 0:
+			return "", nil, illegal(r.NumIid, 0, "num"), false
+		}
+		params := top.Params{}.
+			Set("session", session).
+			Set("num_iid", r.NumIid).
+			Set("num", r.Num)
+		return MethodItemUpdateListing, params, RowResult{}, true
+	}))
+}
+
+// 批量下架, 逐行返回结果
+func (is *ItemServiceImpl) Delisting(ctx context.Context, session string, numIids []int64) []RowResult {
+	keys := make([]rowKey, len(numIids))
+	for i, id := range numIids {
+		keys[i] = rowKey{NumIid: id}
+	}
+
+	return markDelisted(ctx, is.invalidateOnSale(ctx, is.callRows(ctx, keys, func(i int) (string, top.Params, RowResult, bool) {
+		if numIids[i] <= 0 {
+			return "", nil, illegal(numIids[i], 0, "num_iid"), false
+		}
+		params := top.Params{}.
+			Set("session", session).
+			Set("num_iid", numIids[i])
+		return MethodItemUpdateDelisting, params, RowResult{}, true
+	})))
+}
@@ -0,0 +1,148 @@
+package items
+
+import (
+	"context"
+	"strconv"
+	"tbTool/api/service/shop"
+	"tbTool/api/service/top"
+)
+
+const (
+	MethodItemQuantityUpdate = "taobao.item.quantity.update"
+	MethodItemSkuUpdate      = "taobao.item.sku.update"
+	MethodItemSkuGet         = "taobao.item.sku.get"
+	MethodItemUpdate         = "taobao.item.update"
+
+	// taobao.item.quantity.update type: 1全量更新 2增量更新
+	QuantityFull        = 1
+	QuantityIncremental = 2
+)
+
+type QuantityUpdate struct {
+	NumIid int64 `json:"num_iid"`
+	// 为0时更新商品总库存
+	SkuID    int64  `json:"sku_id"`
+	Quantity *int64 `json:"quantity"`
+	Type     int    `json:"type"`
+}
+
+type PriceUpdate struct {
+	NumIid int64 `json:"num_iid"`
+	// 为0时更新商品价格
+	SkuID int64 `json:"sku_id"`
+	// 不传时按 sku_id 查询
+	Properties string `json:"properties"`
+	Price      string `json:"price"`
+}
+
+// 批量更新库存, 逐行返回结果
+func (is *ItemServiceImpl) UpdateQuantity(ctx context.Context, session string, rows []QuantityUpdate) []RowResult {
+	keys := make([]rowKey, len(rows))
+	for i, r := range rows {
+		keys[i] = rowKey{NumIid: r.NumIid, SkuID: r.SkuID}
+	}
+
+	return is.invalidateOnSale(ctx, is.callRows(ctx, keys, func(i int) (string, top.Params, RowResult, bool) {
+		params, res, ok := quantityParams(session, rows[i])
+		return MethodItemQuantityUpdate, params, res, ok
+	}))
+}
+
+func quantityParams(session string, r QuantityUpdate) (top.Params, RowResult, bool) {
+	switch {
+	case r.NumIid <= 0:
+		return nil, illegal(r.NumIid, r.SkuID, "num_iid"), false
+	case r.Quantity == nil || (*r.Quantity < 0 && r.Type != QuantityIncremental):
+		return nil, illegal(r.NumIid, r.SkuID, "quantity"), false
+	case r.Type != 0 && r.Type != QuantityFull && r.Type != QuantityIncremental:
+		return nil, illegal(r.NumIid, r.SkuID, "type"), false
+	}
+
+	params := top.Params{}.
+		Set("session", session).
+		Set("num_iid", r.NumIid).
+		Set("quantity", *r.Quantity)
+	if r.SkuID > 0 {
+		params.Set("sku_id", r.SkuID)
+	}
+	if r.Type != 0 {
+		params.Set("type", r.Type)
+	}
+	return params, RowResult{}, true
+}
+
+// 批量更新价格, sku_id 为0时更新商品一口价
+func (is *ItemServiceImpl) UpdatePrice(ctx context.Context, session string, rows []PriceUpdate) []RowResult {
+	keys := make([]rowKey, len(rows))
+	for i, r := range rows {
+		keys[i] = rowKey{NumIid: r.NumIid, SkuID: r.SkuID}
+	}
+
+	return is.invalidateOnSale(ctx, runBatch(ctx, keys, func(ctx context.Context, i int) RowResult {
+		r := rows[i]
+		if r.NumIid <= 0 {
+			return illegal(r.NumIid, r.SkuID, "num_iid")
+		}
+		if p, err := strconv.ParseFloat(r.Price, 64); err != nil || p <= 0 {
+			return illegal(r.NumIid, r.SkuID, "price")
+		}
+
+		if r.SkuID <= 0 && r.Properties == "" {
+			params := top.Params{}.
+				Set("session", session).
+				Set("num_iid", r.NumIid).
+				Set("price", r.Price)
+			return rowResult(r.NumIid, r.SkuID, is.cli.Call(ctx, MethodItemUpdate, params, nil))
+		}
+
+		properties := r.Properties
+		if properties == "" {
+			sku, err := is.skuGet(ctx, session, r.NumIid, r.SkuID)
+			if err != nil {
+				return rowResult(r.NumIid, r.SkuID, err)
+			}
+			properties = sku.Properties
+		}
+
+		params := top.Params{}.
+			Set("session", session).
+			Set("num_iid", r.NumIid).
+			Set("properties", properties).
+			Set("price", r.Price)
+		return rowResult(r.NumIid, r.SkuID, is.cli.Call(ctx, MethodItemSkuUpdate, params, nil))
+	}))
+}
+
+// 任意一行写入成功后失效店铺的出售中商品缓存
+func (is *ItemServiceImpl) invalidateOnSale(ctx context.Context, results []RowResult) []RowResult {
+	s, ok := shop.FromContext(ctx)
+	if !ok {
+		return results
+	}
+	for _, r := range results {
+		if r.Status == RowSuccess {
+			is.cache.Invalidate(ctx, s.ID)
+			break
+		}
+	}
+	return results
+}
+
+func (is *ItemServiceImpl) skuGet(ctx context.Context, session string, numIid, skuID int64) (*Sku, error) {
+	params := top.Params{}.
+		Set("session", session).
+		Set("fields", "sku_id,properties,quantity,price").
+		Set("num_iid", numIid).
+		Set("sku_id", skuID)
+
+	var resp struct {
+		Sku *Sku `json:"sku"`
+	}
+	if err := is.cli.Call(ctx, MethodItemSkuGet, params, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Sku == nil {
+		return nil, ErrSkuNotFound
+	}
+	return resp.Sku, nil
+}
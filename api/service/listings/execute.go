@@ -0,0 +1,80 @@
+package listings
+
+import (
+	"context"
+	"github.com/jinzhu/gorm"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/logger"
+	"tbTool/api/service/items"
+	"tbTool/api/service/shop"
+	"tbTool/api/service/top"
+	"time"
+)
+
+// 执行一个已抢占的任务, 逐个商品记录结果; 商品部分失败时任务仍为 done
+func (ls *ListingServiceImpl) execute(ctx context.Context, db *gorm.DB, a *ListingAction) {
+	ctx, cancel := context.WithTimeout(ctx, executeTimeout)
+	defer cancel()
+
+	succeeded, failed, err := ls.run(ctx, db, a)
+	status, msg := StatusDone, ""
+	if err != nil {
+		status, msg = StatusFailed, truncate(err.Error())
+		logger.Errorf("listing action %d shop %s error: %v", a.ID, a.ShopID, err)
+	} else {
+		logger.Infof("listing action %d shop %s done: %d succeeded, %d failed", a.ID, a.ShopID, succeeded, failed)
+	}
+
+	if _, err := transition(db, a.ID, StatusRunning, status, map[string]interface{}{
+		"succeeded": succeeded,
+		"failed":    failed,
+		"msg":       msg,
+		"done_at":   time.Now(),
+	}); err != nil {
+		logger.Errorf("listing action %d save status error: %v", a.ID, err)
+	}
+}
+
+func (ls *ListingServiceImpl) run(ctx context.Context, db *gorm.DB, a *ListingAction) (int, int, error) {
+	s, err := shop.Get(a.ShopID)
+	if err != nil {
+		return 0, 0, err
+	}
+	ctx = top.WithQuotaMode(shop.NewContext(ctx, s), top.QuotaWait)
+
+	session, err := ls.ss.Session(ctx, a.SellerID)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var list []ListingActionItem
+	if err := db.Where("action_id = ?", a.ID).Order("id").Find(&list).Error; err != nil {
+		return 0, 0, err
+	}
+
+	var results []items.RowResult
+	switch a.Type {
+	case TypeListing:
+		rows := make([]items.ListingUpdate, len(list))
+		for i, it := range list {
+			rows[i] = items.ListingUpdate{NumIid: it.NumIid, Num: it.Num}
+		}
+		results = ls.is.Listing(ctx, session, rows)
+	default:
+		numIids := make([]int64, len(list))
+		for i, it := range list {
+			numIids[i] = it.NumIid
+		}
+		results = ls.is.Delisting(ctx, session, numIids)
+	}
+
+	var succeeded, failed int
+	for i, r := range results {
+		list[i].Status, list[i].Code, list[i].Msg = r.Status, r.Code, truncate(r.Msg)
+		if r.Status == items.RowSuccess {
+			succeeded++
+		} else {
+			failed++
+		}
+	}
+	return succeeded, failed, saveResults(db, list)
+}
@@ -0,0 +1,62 @@
+package listings
+
+import (
+	"context"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/conf"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/grpool"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/logger"
+	"sync"
+	"time"
+)
+
+const defaultPollInterval = 30 * time.Second
+
+// 定时轮询并执行到期的上下架任务
+type ScheduleJob struct {
+	ls   ListingService
+	stop chan struct{}
+	once sync.Once
+}
+
+func NewScheduleJob(ls ListingService) *ScheduleJob {
+	return &ScheduleJob{
+		ls:   ls,
+		stop: make(chan struct{}),
+	}
+}
+
+func (j *ScheduleJob) Start() error {
+	interval := conf.GetDurationFormConfigFile("listings.poll_interval")
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+
+	return grpool.Submit(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-j.stop:
+				return
+			case <-ticker.C:
+				j.runOnce()
+			}
+		}
+	})
+}
+
+func (j *ScheduleJob) Stop() error {
+	j.once.Do(func() { close(j.stop) })
+	return nil
+}
+
+func (j *ScheduleJob) runOnce() {
+	n, err := j.ls.RunDue(context.Background())
+	if err != nil {
+		logger.Errorf("listings run due actions error: %v", err)
+		return
+	}
+	if n > 0 {
+		logger.Infof("listings executed %d due actions", n)
+	}
+}
@@ -0,0 +1,313 @@
+package listings
+
+import (
+	"context"
+	"errors"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/grpool"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/logger"
+	"sync"
+	"sync/atomic"
+	"tbTool/api/service/items"
+	"tbTool/api/service/oauth"
+	"tbTool/api/service/shop"
+	"time"
+)
+
+const (
+	TypeListing   = "listing"
+	TypeDelisting = "delisting"
+
+	StatusPending  = "pending"
+	StatusRunning  = "running"
+	StatusDone     = "done"
+	StatusFailed   = "failed"
+	StatusCanceled = "canceled"
+
+	DefaultActionLimit = 50
+	MaxActionLimit     = 200
+
+	// 单次轮询最多执行的到期任务数
+	dueBatch = 100
+	// 到期任务的并发执行数
+	dueParallelism = 5
+	// 单个任务的执行超时, 超过两倍仍为执行中视为进程中断
+	executeTimeout = 5 * time.Minute
+	msgMaxLen      = 500
+)
+
+var (
+	ErrUnknownAction    = errors.New("listings: unknown action")
+	ErrActionNotPending = errors.New("listings: action is not pending")
+	ErrExecuteAtPassed  = errors.New("listings: execute_at has passed")
+)
+
+type ScheduleRequest struct {
+	Type string
+	// 执行时使用该卖家的授权
+	SellerID  string
+	ExecuteAt time.Time
+	// 下架时忽略 Num
+	Items []items.ListingUpdate
+}
+
+type ActionItem struct {
+	NumIid int64  `json:"num_iid"`
+	Num    int64  `json:"num,omitempty"`
+	Status string `json:"status,omitempty"`
+	Code   int32  `json:"code,omitempty"`
+	Msg    string `json:"msg,omitempty"`
+}
+
+type Action struct {
+	ID        int64        `json:"id"`
+	Type      string       `json:"type"`
+	Status    string       `json:"status"`
+	ExecuteAt time.Time    `json:"execute_at"`
+	Total     int          `json:"total"`
+	Succeeded int          `json:"succeeded"`
+	Failed    int          `json:"failed"`
+	Msg       string       `json:"msg,omitempty"`
+	StartedAt *time.Time   `json:"started_at,omitempty"`
+	DoneAt    *time.Time   `json:"done_at,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+	Items     []ActionItem `json:"items,omitempty"`
+}
+
+type ActionQuery struct {
+	// 为空时返回全部状态
+	Status string
+	Limit  int
+}
+
+type ListingService interface {
+	// 为当前店铺创建定时上下架任务
+	Schedule(ctx context.Context, req ScheduleRequest) (*Action, error)
+	// 查询任务及逐个商品的执行结果
+	Get(ctx context.Context, id int64) (*Action, error)
+	// 当前店铺的任务列表, 按创建时间倒序, 不含商品明细
+	List(ctx context.Context, q ActionQuery) ([]Action, error)
+	// 取消未执行的任务
+	Cancel(ctx context.Context, id int64) (*Action, error)
+	// 有界并发执行所有店铺到期的任务, 返回执行的任务数
+	RunDue(ctx context.Context) (int, error)
+}
+
+type ListingServiceImpl struct {
+	is items.ItemService
+	ss oauth.SessionService
+}
+
+func NewListingServiceImpl(is items.ItemService, ss oauth.SessionService) ListingService {
+	return &ListingServiceImpl{
+		is: is,
+		ss: ss,
+	}
+}
+
+func (ls *ListingServiceImpl) Schedule(ctx context.Context, req ScheduleRequest) (*Action, error) {
+	s, ok := shop.FromContext(ctx)
+	if !ok {
+		return nil, shop.ErrMissingShop
+	}
+	if !req.ExecuteAt.After(time.Now()) {
+		return nil, ErrExecuteAtPassed
+	}
+	db, err := getDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &ListingAction{
+		ShopID:    s.ID,
+		SellerID:  req.SellerID,
+		Type:      req.Type,
+		Status:    StatusPending,
+		ExecuteAt: req.ExecuteAt,
+		Total:     len(req.Items),
+	}
+	list := make([]ListingActionItem, len(req.Items))
+	for i, it := range req.Items {
+		list[i] = ListingActionItem{NumIid: it.NumIid, Status: StatusPending}
+		if req.Type == TypeListing {
+			list[i].Num = it.Num
+		}
+	}
+	if err := createAction(db, a, list); err != nil {
+		return nil, err
+	}
+	return toAction(a, list), nil
+}
+
+func (ls *ListingServiceImpl) Get(ctx context.Context, id int64) (*Action, error) {
+	s, ok := shop.FromContext(ctx)
+	if !ok {
+		return nil, shop.ErrMissingShop
+	}
+	db, err := getDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	a, list, err := loadAction(db, s.ID, id)
+	if err != nil {
+		return nil, err
+	}
+	return toAction(a, list), nil
+}
+
+func (ls *ListingServiceImpl) List(ctx context.Context, q ActionQuery) ([]Action, error) {
+	s, ok := shop.FromContext(ctx)
+	if !ok {
+		return nil, shop.ErrMissingShop
+	}
+	if q.Limit <= 0 {
+		q.Limit = DefaultActionLimit
+	}
+	if q.Limit > MaxActionLimit {
+		q.Limit = MaxActionLimit
+	}
+	db, err := getDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := queryActions(db, s.ID, q)
+	if err != nil {
+		return nil, err
+	}
+	actions := make([]Action, 0, len(rows))
+	for i := range rows {
+		actions = append(actions, *toAction(&rows[i], nil))
+	}
+	return actions, nil
+}
+
+func (ls *ListingServiceImpl) Cancel(ctx context.Context, id int64) (*Action, error) {
+	s, ok := shop.FromContext(ctx)
+	if !ok {
+		return nil, shop.ErrMissingShop
+	}
+	db, err := getDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	a, _, err := loadAction(db, s.ID, id)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	ok, err = transition(db, a.ID, StatusPending, StatusCanceled, map[string]interface{}{"done_at": now})
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrActionNotPending
+	}
+	return ls.Get(ctx, id)
+}
+
+func (ls *ListingServiceImpl) RunDue(ctx context.Context) (int, error) {
+	db, err := getDB(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	stale, err := staleActions(db, now.Add(-2*executeTimeout))
+	if err != nil {
+		return 0, err
+	}
+	for _, a := range stale {
+		if _, err := transition(db, a.ID, StatusRunning, StatusFailed, map[string]interface{}{
+			"msg": "interrupted", "done_at": now,
+		}); err != nil {
+			logger.Errorf("listing action %d mark interrupted error: %v", a.ID, err)
+		}
+	}
+
+	due, err := dueActions(db, now, dueBatch)
+	if err != nil {
+		return 0, err
+	}
+	if len(due) == 0 {
+		return 0, nil
+	}
+
+	pool, err := grpool.NewPool(dueParallelism)
+	if err != nil {
+		return 0, err
+	}
+	defer pool.Release()
+
+	var (
+		wg sync.WaitGroup
+		n  int32
+	)
+	for i := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		a := &due[i]
+		wg.Add(1)
+		// 在工作协程内抢占, started_at 为实际开始执行的时间, 排队中的任务仍可被其它副本抢到
+		task := func() {
+			defer wg.Done()
+			if ctx.Err() != nil {
+				return
+			}
+			// 多副本轮询时只有抢到的副本执行
+			claimed, err := transition(db, a.ID, StatusPending, StatusRunning, map[string]interface{}{"started_at": time.Now()})
+			if err != nil {
+				logger.Errorf("listing action %d claim error: %v", a.ID, err)
+				return
+			}
+			if !claimed {
+				return
+			}
+			ls.execute(ctx, db, a)
+			atomic.AddInt32(&n, 1)
+		}
+		if err := pool.Submit(task); err != nil {
+			wg.Done()
+			logger.Errorf("listing action %d submit error: %v", a.ID, err)
+		}
+	}
+	wg.Wait()
+	return int(n), nil
+}
+
+func toAction(a *ListingAction, list []ListingActionItem) *Action {
+	action := &Action{
+		ID:        a.ID,
+		Type:      a.Type,
+		Status:    a.Status,
+		ExecuteAt: a.ExecuteAt,
+		Total:     a.Total,
+		Succeeded: a.Succeeded,
+		Failed:    a.Failed,
+		Msg:       a.Msg,
+		StartedAt: a.StartedAt,
+		DoneAt:    a.DoneAt,
+		CreatedAt: a.CreatedAt,
+	}
+	for _, it := range list {
+		action.Items = append(action.Items, ActionItem{
+			NumIid: it.NumIid,
+			Num:    it.Num,
+			Status: it.Status,
+			Code:   it.Code,
+			Msg:    it.Msg,
+		})
+	}
+	return action
+}
+
+// 按字符截断, 避免超出列宽
+func truncate(s string) string {
+	r := []rune(s)
+	if len(r) <= msgMaxLen {
+		return s
+	}
+	return string(r[:msgMaxLen])
+}
@@ -0,0 +1,154 @@
+package listings
+
+import (
+	"context"
+	"github.com/jinzhu/gorm"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/orm"
+	"sync"
+	"tbTool/api/tools/common"
+	"time"
+)
+
+// 定时上下架任务
+type ListingAction struct {
+	ID     int64  `gorm:"primary_key"`
+	ShopID string `gorm:"type:varchar(64);index:idx_shop_created"`
+	// 执行时使用该卖家的授权, 为空时使用店铺默认卖家
+	SellerID  string    `gorm:"type:varchar(64)"`
+	Type      string    `gorm:"type:varchar(16)"`
+	Status    string    `gorm:"type:varchar(16);index:idx_status_execute"`
+	ExecuteAt time.Time `gorm:"index:idx_status_execute"`
+	Total     int
+	Succeeded int
+	Failed    int
+	Msg       string `gorm:"type:varchar(512)"`
+	StartedAt *time.Time
+	DoneAt    *time.Time
+	CreatedAt time.Time `gorm:"index:idx_shop_created"`
+	UpdatedAt time.Time
+}
+
+func (ListingAction) TableName() string {
+	return "tb_listing_action"
+}
+
+// 任务中的单个商品及其执行结果
+type ListingActionItem struct {
+	ID       int64 `gorm:"primary_key"`
+	ActionID int64 `gorm:"index:idx_action"`
+	NumIid   int64
+	// 上架数量, 下架时为0
+	Num    int64
+	Status string `gorm:"type:varchar(16)"`
+	Code   int32
+	Msg    string `gorm:"type:varchar(512)"`
+}
+
+func (ListingActionItem) TableName() string {
+	return "tb_listing_action_item"
+}
+
+var (
+	migrateMu sync.Mutex
+	migrated  bool
+)
+
+func getDB(ctx context.Context) (*gorm.DB, error) {
+	cli, err := orm.GetClient(ctx, common.OrmName)
+	if err != nil {
+		return nil, err
+	}
+
+	migrateMu.Lock()
+	defer migrateMu.Unlock()
+	if !migrated {
+		if err := cli.AutoMigrate(&ListingAction{}, &ListingActionItem{}).Error; err != nil {
+			return nil, err
+		}
+		migrated = true
+	}
+	return cli.DB, nil
+}
+
+func createAction(db *gorm.DB, a *ListingAction, list []ListingActionItem) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(a).Error; err != nil {
+			return err
+		}
+		for i := range list {
+			list[i].ActionID = a.ID
+			if err := tx.Create(&list[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func loadAction(db *gorm.DB, shopID string, id int64) (*ListingAction, []ListingActionItem, error) {
+	a := &ListingAction{}
+	err := db.Where("id = ? AND shop_id = ?", id, shopID).First(a).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil, ErrUnknownAction
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var list []ListingActionItem
+	if err := db.Where("action_id = ?", id).Order("id").Find(&list).Error; err != nil {
+		return nil, nil, err
+	}
+	return a, list, nil
+}
+
+func queryActions(db *gorm.DB, shopID string, q ActionQuery) ([]ListingAction, error) {
+	scope := db.Where("shop_id = ?", shopID)
+	if q.Status != "" {
+		scope = scope.Where("status = ?", q.Status)
+	}
+	var rows []ListingAction
+	err := scope.Order("id DESC").Limit(q.Limit).Find(&rows).Error
+	return rows, err
+}
+
+// 状态从 from 切换为 to, 多副本下只有一个能切换成功
+func transition(db *gorm.DB, id int64, from, to string, fields map[string]interface{}) (bool, error) {
+	updates := map[string]interface{}{"status": to}
+	for k, v := range fields {
+		updates[k] = v
+	}
+	res := db.Model(&ListingAction{}).Where("id = ? AND status = ?", id, from).Updates(updates)
+	return res.RowsAffected == 1, res.Error
+}
+
+// 到期待执行的任务
+func dueActions(db *gorm.DB, now time.Time, limit int) ([]ListingAction, error) {
+	var rows []ListingAction
+	err := db.Where("status = ? AND execute_at <= ?", StatusPending, now).
+		Order("execute_at").Limit(limit).Find(&rows).Error
+	return rows, err
+}
+
+// 执行中进程退出遗留的任务
+func staleActions(db *gorm.DB, before time.Time) ([]ListingAction, error) {
+	var rows []ListingAction
+	err := db.Where("status = ? AND started_at < ?", StatusRunning, before).Find(&rows).Error
+	return rows, err
+}
+
+func saveResults(db *gorm.DB, list []ListingActionItem) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for i := range list {
+			err := tx.Model(&ListingActionItem{}).Where("id = ?", list[i].ID).Updates(map[string]interface{}{
+				"status": list[i].Status,
+				"code":   list[i].Code,
+				"msg":    list[i].Msg,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
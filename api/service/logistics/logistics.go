@@ -0,0 +1,68 @@
+package logistics
+
+import (
+	"context"
+	"errors"
+	"tbTool/api/service/top"
+)
+
+const (
+	MethodLogisticsOfflineSend = "taobao.logistics.offline.send"
+)
+
+// 接口调用成功但 is_success 为 false, 发货未生效
+var ErrSendFailed = errors.New("logistics: offline send was not successful")
+
+type OfflineSend struct {
+	Tid int64 `json:"tid" form:"tid" binding:"gt=0"`
+	// 运单号
+	OutSid string `json:"out_sid" form:"out_sid" binding:"required"`
+	// 物流公司编码, 如 YTO/ZTO/SF
+	CompanyCode string `json:"company_code" form:"company_code" binding:"required"`
+	// 拆单发货时的子订单, 逗号分隔
+	SubTid  string `json:"sub_tid,omitempty" form:"sub_tid"`
+	IsSplit bool   `json:"is_split,omitempty" form:"is_split"`
+}
+
+type Shipping struct {
+	IsSuccess bool `json:"is_success"`
+}
+
+type LogisticsOfflineSendResponse struct {
+	Shipping Shipping `json:"shipping"`
+}
+
+type LogisticsService interface {
+	// 自己联系物流(线下物流)发货
+	OfflineSend(ctx context.Context, session string, req OfflineSend) (*Shipping, error)
+}
+
+type LogisticsServiceImpl struct {
+	cli top.Caller
+}
+
+func NewLogisticsServiceImpl(cli top.Caller) LogisticsService {
+	return &LogisticsServiceImpl{
+		cli: cli,
+	}
+}
+
+func (ls *LogisticsServiceImpl) OfflineSend(ctx context.Context, session string, req OfflineSend) (*Shipping, error) {
+	params := top.Params{}.
+		Set("session", session).
+		Set("tid", req.Tid).
+		Set("out_sid", req.OutSid).
+		Set("company_code", req.CompanyCode)
+	if req.IsSplit {
+		params.Set("is_split", 1).Set("sub_tid", req.SubTid)
+	}
+
+	var resp LogisticsOfflineSendResponse
+	if err := ls.cli.Call(ctx, MethodLogisticsOfflineSend, params, &resp); err != nil {
+		return nil, err
+	}
+	if !resp.Shipping.IsSuccess {
+		return nil, ErrSendFailed
+	}
+	return &resp.Shipping, nil
+}
@@ -0,0 +1,124 @@
+package marketplace
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	PlatformTaobao = "taobao"
+	// 店铺未配置平台时使用
+	DefaultPlatform = PlatformTaobao
+
+	// 各平台实现通过该 dig 分组注册
+	PlatformGroup = "marketplace.platforms"
+)
+
+var (
+	ErrUnknownPlatform = errors.New("marketplace: unknown platform")
+	ErrItemNotFound    = errors.New("marketplace: item not found")
+)
+
+// 平台无关的商品, id 统一为字符串
+type Item struct {
+	ID       string    `json:"id"`
+	Title    string    `json:"title"`
+	Price    string    `json:"price"`
+	Stock    int64     `json:"stock"`
+	OuterID  string    `json:"outer_id,omitempty"`
+	PicURL   string    `json:"pic_url,omitempty"`
+	OnSale   bool      `json:"on_sale"`
+	Modified time.Time `json:"modified"`
+	Skus     []Sku     `json:"skus,omitempty"`
+}
+
+type Sku struct {
+	ID         string `json:"id"`
+	Properties string `json:"properties,omitempty"`
+	Price      string `json:"price,omitempty"`
+	Stock      int64  `json:"stock"`
+	OuterID    string `json:"outer_id,omitempty"`
+}
+
+type ItemQuery struct {
+	PageNo   int
+	PageSize int
+}
+
+type ItemPage struct {
+	Items   []Item `json:"items"`
+	PageNo  int    `json:"page_no"`
+	Total   int    `json:"total"`
+	HasNext bool   `json:"has_next"`
+}
+
+type StockUpdate struct {
+	ItemID string `json:"item_id"`
+	// 为空时更新商品总库存
+	SkuID    string `json:"sku_id"`
+	Quantity int64  `json:"quantity"`
+	// 增量更新, 否则全量覆盖
+	Incremental bool `json:"incremental"`
+}
+
+// 库存更新逐行结果, Status 为 success/error/skipped
+type StockResult struct {
+	ItemID string `json:"item_id"`
+	SkuID  string `json:"sku_id,omitempty"`
+	Status string `json:"status"`
+	Code   int32  `json:"code,omitempty"`
+	Msg    string `json:"msg,omitempty"`
+}
+
+type Order struct {
+	ID        string      `json:"id"`
+	Status    string      `json:"status"`
+	BuyerNick string      `json:"buyer_nick,omitempty"`
+	Payment   string      `json:"payment"`
+	Created   time.Time   `json:"created"`
+	Modified  time.Time   `json:"modified"`
+	Lines     []OrderLine `json:"lines"`
+}
+
+type OrderLine struct {
+	ID       string `json:"id"`
+	ItemID   string `json:"item_id"`
+	SkuID    string `json:"sku_id,omitempty"`
+	Title    string `json:"title"`
+	Price    string `json:"price"`
+	Quantity int64  `json:"quantity"`
+	Status   string `json:"status,omitempty"`
+}
+
+type OrderQuery struct {
+	// 平台原始状态, 为空时查询全部
+	Status   string
+	From     time.Time
+	To       time.Time
+	PageNo   int
+	PageSize int
+}
+
+type OrderPage struct {
+	Orders  []Order `json:"orders"`
+	PageNo  int     `json:"page_no"`
+	Total   int     `json:"total"`
+	HasNext bool    `json:"has_next"`
+}
+
+// 平台无关的店铺操作, session 为平台授权凭证
+type Provider interface {
+	// 解析卖家在该平台的授权凭证, 结果作为其它方法的 session 传入
+	Session(ctx context.Context, sellerID string) (string, error)
+	ListItems(ctx context.Context, session string, q ItemQuery) (*ItemPage, error)
+	GetItem(ctx context.Context, session, id string) (*Item, error)
+	UpdateStock(ctx context.Context, session string, rows []StockUpdate) []StockResult
+	ListOrders(ctx context.Context, session string, q OrderQuery) (*OrderPage, error)
+}
+
+// 一个平台的实现, 以 dig.Group(PlatformGroup) 注册
+type Platform struct {
+	Name     string
+	Provider Provider
+}
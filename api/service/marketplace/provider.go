@@ -0,0 +1,92 @@
+package marketplace
+
+import (
+	"context"
+	"go.uber.org/dig"
+	"tbTool/api/service/shop"
+	"tbTool/pkg/gitlab.xfq.com/wpt-api/g-api/errorcode/base"
+)
+
+// 已注册的平台实现
+type Platforms struct {
+	dig.In
+
+	Platforms []Platform `group:"marketplace.platforms"`
+}
+
+// 按上下文中店铺配置的平台分发到对应实现
+type ShopProvider struct {
+	providers map[string]Provider
+}
+
+func NewShopProvider(in Platforms) Provider {
+	providers := make(map[string]Provider, len(in.Platforms))
+	for _, p := range in.Platforms {
+		providers[p.Name] = p.Provider
+	}
+	return &ShopProvider{providers: providers}
+}
+
+// 店铺所在平台, 未配置时为默认平台
+func PlatformOf(s *shop.Shop) string {
+	if s.Platform == "" {
+		return DefaultPlatform
+	}
+	return s.Platform
+}
+
+func (sp *ShopProvider) provider(ctx context.Context) (Provider, error) {
+	s, ok := shop.FromContext(ctx)
+	if !ok {
+		return nil, shop.ErrMissingShop
+	}
+	p, ok := sp.providers[PlatformOf(s)]
+	if !ok {
+		return nil, ErrUnknownPlatform
+	}
+	return p, nil
+}
+
+func (sp *ShopProvider) Session(ctx context.Context, sellerID string) (string, error) {
+	p, err := sp.provider(ctx)
+	if err != nil {
+		return "", err
+	}
+	return p.Session(ctx, sellerID)
+}
+
+func (sp *ShopProvider) ListItems(ctx context.Context, session string, q ItemQuery) (*ItemPage, error) {
+	p, err := sp.provider(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return p.ListItems(ctx, session, q)
+}
+
+func (sp *ShopProvider) GetItem(ctx context.Context, session, id string) (*Item, error) {
+	p, err := sp.provider(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return p.GetItem(ctx, session, id)
+}
+
+func (sp *ShopProvider) UpdateStock(ctx context.Context, session string, rows []StockUpdate) []StockResult {
+	p, err := sp.provider(ctx)
+	if err != nil {
+		results := make([]StockResult, len(rows))
+		for i, r := range rows {
+			results[i] = StockResult{ItemID: r.ItemID, SkuID: r.SkuID, Status: "error", Code: base.Error, Msg: err.Error()}
+		}
+		return results
+	}
+	return p.UpdateStock(ctx, session, rows)
+}
+
+func (sp *ShopProvider) ListOrders(ctx context.Context, session string, q OrderQuery) (*OrderPage, error) {
+	p, err := sp.provider(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return p.ListOrders(ctx, session, q)
+}
@@ -0,0 +1,199 @@
+package taobao
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"tbTool/api/service/items"
+	"tbTool/api/service/marketplace"
+	"tbTool/api/service/oauth"
+	"tbTool/api/service/top"
+	"tbTool/api/service/trades"
+	"tbTool/pkg/gitlab.xfq.com/wpt-api/g-api/errorcode/base"
+	"time"
+)
+
+const listFields = "num_iid,title,price,num,outer_id,pic_url,approve_status,modified"
+
+// 淘宝平台实现, 基于 ItemService 和 TradeService, 凭证来自淘宝 OAuth 授权
+type Provider struct {
+	is items.ItemService
+	ts trades.TradeService
+	ss oauth.SessionService
+}
+
+func NewProvider(is items.ItemService, ts trades.TradeService, ss oauth.SessionService) marketplace.Platform {
+	return marketplace.Platform{
+		Name:     marketplace.PlatformTaobao,
+		Provider: &Provider{is: is, ts: ts, ss: ss},
+	}
+}
+
+func (p *Provider) Session(ctx context.Context, sellerID string) (string, error) {
+	return p.ss.Session(ctx, sellerID)
+}
+
+func (p *Provider) ListItems(ctx context.Context, session string, q marketplace.ItemQuery) (*marketplace.ItemPage, error) {
+	page, err := p.is.ItemsOnSaleGet(ctx, session, items.OnSaleQuery{
+		Fields:   listFields,
+		PageNo:   q.PageNo,
+		PageSize: q.PageSize,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]marketplace.Item, 0, len(page.Items))
+	for i := range page.Items {
+		list = append(list, toItem(&page.Items[i]))
+	}
+	return &marketplace.ItemPage{
+		Items:   list,
+		PageNo:  page.PageNo,
+		Total:   page.TotalResults,
+		HasNext: page.HasNext,
+	}, nil
+}
+
+func (p *Provider) GetItem(ctx context.Context, session, id string) (*marketplace.Item, error) {
+	numIid, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || numIid <= 0 {
+		return nil, marketplace.ErrItemNotFound
+	}
+	detail, err := p.is.ItemSellerGet(ctx, session, numIid, "")
+	if err != nil {
+		return nil, err
+	}
+	if detail == nil {
+		return nil, marketplace.ErrItemNotFound
+	}
+
+	item := toItem(&detail.Item)
+	for _, sku := range detail.Skus.Sku {
+		item.Skus = append(item.Skus, marketplace.Sku{
+			ID:         strconv.FormatInt(sku.SkuID, 10),
+			Properties: sku.PropertiesName,
+			Price:      sku.Price,
+			Stock:      sku.Quantity,
+			OuterID:    sku.OuterID,
+		})
+	}
+	return &item, nil
+}
+
+func (p *Provider) UpdateStock(ctx context.Context, session string, rows []marketplace.StockUpdate) []marketplace.StockResult {
+	results := make([]marketplace.StockResult, len(rows))
+	updates := make([]items.QuantityUpdate, 0, len(rows))
+	idx := make([]int, 0, len(rows))
+	for i, r := range rows {
+		numIid, err := strconv.ParseInt(r.ItemID, 10, 64)
+		if err != nil {
+			results[i] = illegal(r, "item_id")
+			continue
+		}
+		var skuID int64
+		if r.SkuID != "" {
+			if skuID, err = strconv.ParseInt(r.SkuID, 10, 64); err != nil {
+				results[i] = illegal(r, "sku_id")
+				continue
+			}
+		}
+		quantity := r.Quantity
+		u := items.QuantityUpdate{NumIid: numIid, SkuID: skuID, Quantity: &quantity, Type: items.QuantityFull}
+		if r.Incremental {
+			u.Type = items.QuantityIncremental
+		}
+		updates = append(updates, u)
+		idx = append(idx, i)
+	}
+	if len(updates) == 0 {
+		return results
+	}
+
+	for n, res := range p.is.UpdateQuantity(ctx, session, updates) {
+		r := rows[idx[n]]
+		results[idx[n]] = marketplace.StockResult{
+			ItemID: r.ItemID,
+			SkuID:  r.SkuID,
+			Status: res.Status,
+			Code:   res.Code,
+			Msg:    res.Msg,
+		}
+	}
+	return results
+}
+
+func (p *Provider) ListOrders(ctx context.Context, session string, q marketplace.OrderQuery) (*marketplace.OrderPage, error) {
+	page, err := p.ts.TradesSoldGet(ctx, session, trades.SoldQuery{
+		Status:       q.Status,
+		StartCreated: q.From,
+		EndCreated:   q.To,
+		PageNo:       q.PageNo,
+		PageSize:     q.PageSize,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	orders := make([]marketplace.Order, 0, len(page.Trades))
+	for i := range page.Trades {
+		orders = append(orders, toOrder(&page.Trades[i]))
+	}
+	return &marketplace.OrderPage{
+		Orders:  orders,
+		PageNo:  page.PageNo,
+		Total:   page.TotalResults,
+		HasNext: page.HasNext,
+	}, nil
+}
+
+func toItem(it *items.Item) marketplace.Item {
+	return marketplace.Item{
+		ID:       strconv.FormatInt(it.NumIid, 10),
+		Title:    it.Title,
+		Price:    it.Price,
+		Stock:    it.Num,
+		OuterID:  it.OuterID,
+		PicURL:   it.PicURL,
+		OnSale:   it.ApproveStatus == "onsale",
+		Modified: parseTime(it.Modified),
+	}
+}
+
+func toOrder(t *trades.Trade) marketplace.Order {
+	o := marketplace.Order{
+		ID:        strconv.FormatInt(t.Tid, 10),
+		Status:    t.Status,
+		BuyerNick: t.BuyerNick,
+		Payment:   t.Payment,
+		Created:   parseTime(t.Created),
+		Modified:  parseTime(t.Modified),
+	}
+	for _, line := range t.Orders.Order {
+		o.Lines = append(o.Lines, marketplace.OrderLine{
+			ID:       strconv.FormatInt(line.Oid, 10),
+			ItemID:   strconv.FormatInt(line.NumIid, 10),
+			SkuID:    line.SkuID,
+			Title:    line.Title,
+			Price:    line.Price,
+			Quantity: line.Num,
+			Status:   line.Status,
+		})
+	}
+	return o
+}
+
+func parseTime(s string) time.Time {
+	t, _ := top.ParseTime(s)
+	return t
+}
+
+func illegal(r marketplace.StockUpdate, field string) marketplace.StockResult {
+	return marketplace.StockResult{
+		ItemID: r.ItemID,
+		SkuID:  r.SkuID,
+		Status: items.RowSkipped,
+		Code:   base.ParamIllegal,
+		Msg:    fmt.Sprintf(base.ErrorMsg(base.ParamIllegal), field),
+	}
+}
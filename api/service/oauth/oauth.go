@@ -0,0 +1,339 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v7"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/algs"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/conf"
+	dredis "gitlab.xfq.com/tech-lab/dionysus/pkg/redis"
+	"net/url"
+	"strconv"
+	"tbTool/api/service/shop"
+	"tbTool/api/tools/common"
+	"tbTool/pkg/request"
+	"time"
+)
+
+const (
+	AuthorizeLink = "https://oauth.taobao.com/authorize"
+	TokenLink     = "https://oauth.taobao.com/token"
+
+	tokenKeyPrefix = "tbtool:oauth:token:"
+	stateKeyPrefix = "tbtool:oauth:state:"
+	lockKeyPrefix  = "tbtool:oauth:refresh:"
+	// 店铺下完成过授权的卖家集合
+	sellersKeyPrefix = "tbtool:oauth:sellers:"
+
+	stateTTL = 10 * time.Minute
+	lockTTL  = 10 * time.Second
+	// 提前刷新的时间窗口
+	refreshAhead = 30 * time.Minute
+)
+
+var (
+	ErrNoToken       = errors.New("oauth: seller has not authorized")
+	ErrTokenExpired  = errors.New("oauth: seller authorization expired")
+	ErrInvalidState  = errors.New("oauth: invalid or expired state")
+	ErrMissingSeller = errors.New("oauth: missing seller id")
+	// 淘宝返回的授权用户与发起授权时指定的卖家不一致
+	ErrSellerMismatch = errors.New("oauth: authorized user does not match seller id")
+	// 卖家未在当前店铺下授权
+	ErrSellerNotBound = errors.New("oauth: seller is not bound to this shop")
+)
+
+type Token struct {
+	AppKey           string    `json:"app_key"`
+	SellerID         string    `json:"seller_id"`
+	AccessToken      string    `json:"access_token"`
+	RefreshToken     string    `json:"refresh_token"`
+	TaobaoUserID     string    `json:"taobao_user_id"`
+	TaobaoUserNick   string    `json:"taobao_user_nick"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+func (t *Token) needRefresh(now time.Time) bool {
+	return now.Add(refreshAhead).After(t.ExpiresAt)
+}
+
+// oauth.taobao.com/token 返回
+type tokenResponse struct {
+	AccessToken      string      `json:"access_token"`
+	RefreshToken     string      `json:"refresh_token"`
+	ExpiresIn        json.Number `json:"expires_in"`
+	ReExpiresIn      json.Number `json:"re_expires_in"`
+	TaobaoUserID     string      `json:"taobao_user_id"`
+	TaobaoUserNick   string      `json:"taobao_user_nick"`
+	Error            string      `json:"error"`
+	ErrorDescription string      `json:"error_description"`
+}
+
+type SessionService interface {
+	// 生成授权跳转地址, state 与 sellerID 绑定
+	AuthorizeURL(ctx context.Context, sellerID string) (string, error)
+	// 授权回调, code 换取 token 并保存
+	Exchange(ctx context.Context, code, state string) (*Token, error)
+	// 获取卖家可用的 session, 临近过期时自动刷新
+	Session(ctx context.Context, sellerID string) (string, error)
+}
+
+// 授权 state 对应的店铺和卖家, 回调时不带店铺标识
+type authState struct {
+	ShopID   string `json:"shop_id"`
+	SellerID string `json:"seller_id"`
+}
+
+type SessionServiceImpl struct{}
+
+func NewSessionServiceImpl() SessionService {
+	return &SessionServiceImpl{}
+}
+
+func (ss *SessionServiceImpl) AuthorizeURL(ctx context.Context, sellerID string) (string, error) {
+	s, ok := shop.FromContext(ctx)
+	if !ok {
+		return "", shop.ErrMissingShop
+	}
+	sellerID = algs.FirstNotEmpty(sellerID, s.SellerID)
+	if sellerID == "" {
+		return "", ErrMissingSeller
+	}
+	rc, err := dredis.GetClient(ctx, common.RedisName)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := randomState()
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(authState{ShopID: s.ID, SellerID: sellerID})
+	if err != nil {
+		return "", err
+	}
+	if err := rc.Set(stateKeyPrefix+state, data, stateTTL).Err(); err != nil {
+		return "", err
+	}
+
+	values := url.Values{}
+	values.Set("response_type", "code")
+	values.Set("client_id", s.AppKey)
+	values.Set("redirect_uri", redirectURI())
+	values.Set("state", state)
+	values.Set("view", "web")
+	return AuthorizeLink + "?" + values.Encode(), nil
+}
+
+func (ss *SessionServiceImpl) Exchange(ctx context.Context, code, state string) (*Token, error) {
+	rc, err := dredis.GetClient(ctx, common.RedisName)
+	if err != nil {
+		return nil, err
+	}
+
+	// 取出并删除在同一事务内完成, 同一 state 只能兑换一次
+	pipe := rc.TxPipeline()
+	get := pipe.Get(stateKeyPrefix + state)
+	pipe.Del(stateKeyPrefix + state)
+	if _, err := pipe.Exec(); err != nil && err != redis.Nil {
+		return nil, err
+	}
+	data, err := get.Bytes()
+	if err == redis.Nil {
+		return nil, ErrInvalidState
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var as authState
+	if err := json.Unmarshal(data, &as); err != nil {
+		return nil, ErrInvalidState
+	}
+	s, err := shop.Get(as.ShopID)
+	if err != nil {
+		return nil, err
+	}
+
+	values := url.Values{}
+	values.Set("grant_type", "authorization_code")
+	values.Set("code", code)
+	values.Set("redirect_uri", redirectURI())
+
+	token, err := ss.requestToken(ctx, s, as.SellerID, values)
+	if err != nil {
+		return nil, err
+	}
+	// 卖家id可以是淘宝用户id或昵称, 防止以他人账号授权覆盖指定卖家的 token
+	if token.TaobaoUserID != as.SellerID && token.TaobaoUserNick != as.SellerID {
+		return nil, ErrSellerMismatch
+	}
+	if err := saveToken(rc, token); err != nil {
+		return nil, err
+	}
+	if err := rc.SAdd(sellersKeyPrefix+s.ID, as.SellerID).Err(); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func (ss *SessionServiceImpl) Session(ctx context.Context, sellerID string) (string, error) {
+	s, ok := shop.FromContext(ctx)
+	if !ok {
+		return "", shop.ErrMissingShop
+	}
+	if s.Session != "" {
+		return s.Session, nil
+	}
+	sellerID = algs.FirstNotEmpty(sellerID, s.SellerID)
+	if sellerID == "" {
+		return "", ErrMissingSeller
+	}
+	rc, err := dredis.GetClient(ctx, common.RedisName)
+	if err != nil {
+		return "", err
+	}
+	// 同一 app_key 下的 token 跨店铺共享, 只允许使用店铺默认卖家和在本店铺授权过的卖家
+	if sellerID != s.SellerID {
+		bound, err := rc.SIsMember(sellersKeyPrefix+s.ID, sellerID).Result()
+		if err != nil {
+			return "", err
+		}
+		if !bound {
+			return "", ErrSellerNotBound
+		}
+	}
+
+	token, err := loadToken(rc, s.AppKey, sellerID)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	if !token.needRefresh(now) {
+		return token.AccessToken, nil
+	}
+
+	// 多副本下只允许一个实例刷新, 未抢到锁时沿用未过期的旧 token
+	lock, locked, err := common.TryLock(rc, lockKeyPrefix+s.AppKey+":"+sellerID, lockTTL)
+	if err != nil || !locked {
+		if now.Before(token.ExpiresAt) {
+			return token.AccessToken, nil
+		}
+		return "", ErrTokenExpired
+	}
+	defer lock.Release()
+
+	if token.RefreshToken == "" || now.After(token.RefreshExpiresAt) {
+		if now.Before(token.ExpiresAt) {
+			return token.AccessToken, nil
+		}
+		return "", ErrTokenExpired
+	}
+
+	values := url.Values{}
+	values.Set("grant_type", "refresh_token")
+	values.Set("refresh_token", token.RefreshToken)
+
+	refreshed, err := ss.requestToken(ctx, s, sellerID, values)
+	if err != nil {
+		if now.Before(token.ExpiresAt) {
+			return token.AccessToken, nil
+		}
+		return "", err
+	}
+	if err := saveToken(rc, refreshed); err != nil {
+		return "", err
+	}
+	return refreshed.AccessToken, nil
+}
+
+func (ss *SessionServiceImpl) requestToken(ctx context.Context, s *shop.Shop, sellerID string, values url.Values) (*Token, error) {
+	values.Set("client_id", s.AppKey)
+	values.Set("client_secret", s.AppSecret)
+
+	_, data, err := request.PostForm(TokenLink, values, 5*time.Second, 1, request.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("oauth: request token: %v", err)
+	}
+
+	var resp tokenResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("oauth: decode token response: %v", err)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("oauth: %s: %s", resp.Error, resp.ErrorDescription)
+	}
+
+	now := time.Now()
+	expiresIn, _ := resp.ExpiresIn.Int64()
+	reExpiresIn, _ := resp.ReExpiresIn.Int64()
+	return &Token{
+		AppKey:           s.AppKey,
+		SellerID:         sellerID,
+		AccessToken:      resp.AccessToken,
+		RefreshToken:     resp.RefreshToken,
+		TaobaoUserID:     resp.TaobaoUserID,
+		TaobaoUserNick:   decodeNick(resp.TaobaoUserNick),
+		ExpiresAt:        now.Add(time.Duration(expiresIn) * time.Second),
+		RefreshExpiresAt: now.Add(time.Duration(reExpiresIn) * time.Second),
+	}, nil
+}
+
+// taobao_user_nick 为 url 编码后的昵称, 解码失败时保留原值
+func decodeNick(nick string) string {
+	if v, err := url.QueryUnescape(nick); err == nil {
+		return v
+	}
+	return nick
+}
+
+// 授权跟随 app_key, 同一卖家在不同应用下的 token 互相独立
+func tokenKey(appKey, sellerID string) string {
+	return tokenKeyPrefix + appKey + ":" + sellerID
+}
+
+func loadToken(rc *redis.Client, appKey, sellerID string) (*Token, error) {
+	data, err := rc.Get(tokenKey(appKey, sellerID)).Bytes()
+	if err == redis.Nil {
+		return nil, ErrNoToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var token Token
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// 过期时间跟随 refresh_token, refresh_token 失效后需要重新授权
+func saveToken(rc *redis.Client, token *Token) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	ttl := time.Until(token.RefreshExpiresAt)
+	if until := time.Until(token.ExpiresAt); until > ttl {
+		ttl = until
+	}
+	return rc.Set(tokenKey(token.AppKey, token.SellerID), data, ttl).Err()
+}
+
+func redirectURI() string {
+	return conf.GetStringFormConfigFile("oauth.redirect_uri")
+}
+
+func randomState() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b) + strconv.FormatInt(time.Now().Unix(), 36), nil
+}
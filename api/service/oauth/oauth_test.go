@@ -0,0 +1,21 @@
+package oauth
+
+import "testing"
+
+func TestDecodeNick(t *testing.T) {
+	cases := []struct {
+		nick string
+		want string
+	}{
+		{"%E6%B5%8B%E8%AF%95%E5%BA%97%E9%93%BA", "测试店铺"},
+		{"tb_seller%2B1", "tb_seller+1"},
+		{"plain", "plain"},
+		// 非法编码保留原值
+		{"bad%zz", "bad%zz"},
+	}
+	for _, c := range cases {
+		if got := decodeNick(c.nick); got != c.want {
+			t.Errorf("decodeNick(%q) = %q, want %q", c.nick, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package pictures
+
+import (
+	"context"
+	"errors"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/conf"
+	"net/http"
+	"path"
+	"strings"
+	"tbTool/api/service/top"
+	"tbTool/pkg/request"
+)
+
+const (
+	MethodPictureUpload = "taobao.picture.upload"
+
+	// 图片空间单张图片上限
+	DefaultMaxSize = 3 << 20
+	// 0 为图片空间默认分类
+	DefaultCategoryID = 0
+)
+
+var (
+	ErrEmpty           = errors.New("pictures: empty file")
+	ErrTooLarge        = errors.New("pictures: file too large")
+	ErrUnsupportedType = errors.New("pictures: unsupported file type")
+	ErrUploadDisabled  = errors.New("pictures: caller does not support file upload")
+	// 接口调用成功但响应中没有图片
+	ErrUploadFailed = errors.New("pictures: upload response has no picture")
+
+	// 按文件内容识别的类型及上传时使用的扩展名
+	allowedTypes = map[string]string{
+		"image/jpeg": ".jpg",
+		"image/png":  ".png",
+		"image/gif":  ".gif",
+	}
+)
+
+type Picture struct {
+	PictureID         int64  `json:"picture_id"`
+	PictureCategoryID int64  `json:"picture_category_id"`
+	PicturePath       string `json:"picture_path"`
+	Title             string `json:"title"`
+	Sizes             int64  `json:"sizes"`
+	Pixel             string `json:"pixel,omitempty"`
+	Status            string `json:"status,omitempty"`
+	Created           string `json:"created,omitempty"`
+	Modified          string `json:"modified,omitempty"`
+}
+
+type PictureUploadResponse struct {
+	Picture *Picture `json:"picture"`
+}
+
+type UploadRequest struct {
+	CategoryID int64
+	// 图片空间中的标题, 为空时使用文件名
+	Title    string
+	FileName string
+	Data     []byte
+}
+
+type PictureService interface {
+	// 上传图片到卖家图片空间
+	Upload(ctx context.Context, session string, req UploadRequest) (*Picture, error)
+	// 单张图片大小上限
+	MaxSize() int64
+}
+
+type PictureServiceImpl struct {
+	cli top.Caller
+}
+
+func NewPictureServiceImpl(cli top.Caller) PictureService {
+	return &PictureServiceImpl{
+		cli: cli,
+	}
+}
+
+func (ps *PictureServiceImpl) MaxSize() int64 {
+	if n := conf.GetSizeInBytesFormConfigFile("pictures.max_size"); n > 0 {
+		return int64(n)
+	}
+	return DefaultMaxSize
+}
+
+func (ps *PictureServiceImpl) Upload(ctx context.Context, session string, req UploadRequest) (*Picture, error) {
+	uc, ok := ps.cli.(top.UploadCaller)
+	if !ok {
+		return nil, ErrUploadDisabled
+	}
+	if len(req.Data) == 0 {
+		return nil, ErrEmpty
+	}
+	if int64(len(req.Data)) > ps.MaxSize() {
+		return nil, ErrTooLarge
+	}
+	// 不信任客户端声明的类型, 按内容识别
+	contentType := http.DetectContentType(req.Data)
+	ext, ok := allowedTypes[contentType]
+	if !ok {
+		return nil, ErrUnsupportedType
+	}
+
+	name := strings.TrimSuffix(path.Base(req.FileName), path.Ext(req.FileName))
+	if name == "" || name == "." || name == "/" {
+		name = "picture"
+	}
+	title := req.Title
+	if title == "" {
+		title = name
+	}
+
+	params := top.Params{}.
+		Set("session", session).
+		Set("picture_category_id", req.CategoryID).
+		Set("image_input_title", name+ext).
+		Set("title", title)
+	files := []request.File{{Field: "img", FileName: name + ext, ContentType: contentType, Data: req.Data}}
+
+	var resp PictureUploadResponse
+	if err := uc.Upload(ctx, MethodPictureUpload, params, files, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Picture == nil {
+		return nil, ErrUploadFailed
+	}
+	return resp.Picture, nil
+}
@@ -0,0 +1,151 @@
+package quota
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"github.com/go-redis/redis/v7"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/logger"
+	dredis "gitlab.xfq.com/tech-lab/dionysus/pkg/redis"
+	"strconv"
+	"tbTool/api/service/top"
+	"tbTool/api/tools/common"
+	"time"
+)
+
+const keyPrefix = "tbtool:quota:"
+
+// 令牌桶, 桶状态存在 redis hash 中, 所有副本共享
+// 一次调用需要同时从多个桶(接口级/应用级/session级)各取一个额度:
+// 全部有额度时一起扣减并返回 0, 否则都不扣减, 返回还需等待的最长毫秒数
+// ARGV[1] 为当前毫秒时间, 之后每个桶依次为 rate/burst/ttl
+var takeScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local tokens = {}
+local stamps = {}
+local wait = 0
+for i = 1, #KEYS do
+	local rate = tonumber(ARGV[i * 3 - 1])
+	local burst = tonumber(ARGV[i * 3])
+	local state = redis.call('HMGET', KEYS[i], 'tokens', 'ts')
+	local t = tonumber(state[1])
+	local ts = tonumber(state[2])
+	if t == nil or ts == nil then
+		t = burst
+		ts = now
+	end
+	if now > ts then
+		t = math.min(burst, t + (now - ts) * rate)
+		ts = now
+	end
+	if t < 1 then
+		wait = math.max(wait, math.ceil((1 - t) / rate))
+	end
+	tokens[i] = t
+	stamps[i] = ts
+end
+for i = 1, #KEYS do
+	local t = tokens[i]
+	if wait == 0 then
+		t = t - 1
+	end
+	redis.call('HMSET', KEYS[i], 'tokens', tostring(t), 'ts', tostring(stamps[i]))
+	redis.call('PEXPIRE', KEYS[i], tonumber(ARGV[i * 3 + 1]))
+end
+return wait
+`)
+
+type RedisLimiter struct{}
+
+func NewRedisLimiter() top.Limiter {
+	return &RedisLimiter{}
+}
+
+func (l *RedisLimiter) Wait(ctx context.Context, appKey, method, session string) error {
+	var rules []*Rule
+	for _, r := range MatchAll(appKey, method) {
+		if r.PerSession && session == "" {
+			continue
+		}
+		rules = append(rules, r)
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+
+	// 多条规则时取最严格的模式和最短的等待时间
+	mode := top.QuotaModeFrom(ctx)
+	maxWait := rules[0].MaxWait
+	failFast := false
+	for _, r := range rules {
+		if r.Mode == ModeFailFast {
+			failFast = true
+		}
+		if r.MaxWait < maxWait {
+			maxWait = r.MaxWait
+		}
+	}
+	if mode == top.QuotaDefault {
+		mode = top.QuotaWait
+		if failFast {
+			mode = top.QuotaFailFast
+		}
+	}
+
+	deadline := time.Now().Add(maxWait)
+	for {
+		wait, err := l.take(ctx, appKey, session, rules)
+		if err != nil {
+			// redis 不可用时放行, 避免限流器成为单点
+			logger.Errorf("quota take %s/%s error: %v", appKey, method, err)
+			return nil
+		}
+		if wait <= 0 {
+			return nil
+		}
+
+		if mode == top.QuotaFailFast || time.Now().Add(wait).After(deadline) {
+			return &top.QuotaError{AppKey: appKey, Method: method, RetryAfter: wait}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (l *RedisLimiter) take(ctx context.Context, appKey, session string, rules []*Rule) (time.Duration, error) {
+	rc, err := dredis.GetClient(ctx, common.RedisName)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	keys := make([]string, len(rules))
+	args := []interface{}{now}
+	for i, r := range rules {
+		keys[i] = bucketKey(appKey, session, r)
+		rate := float64(r.Limit) / float64(r.Period/time.Millisecond)
+		// 桶补满所需时间之后状态可以丢弃
+		ttl := int64(float64(r.Burst)/rate) + 1000
+		args = append(args, strconv.FormatFloat(rate, 'f', -1, 64), r.Burst, ttl)
+	}
+
+	ms, err := takeScript.Run(rc, keys, args...).Int64()
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// 通配接口的规则在同一 app_key 下共用一个桶; {app_key} 保证同一调用的桶在集群中位于同一 slot
+// session 取摘要, 避免凭证出现在 key 中
+func bucketKey(appKey, session string, r *Rule) string {
+	key := keyPrefix + "{" + appKey + "}:" + r.Method
+	if r.PerSession {
+		sum := md5.Sum([]byte(session))
+		key += ":session:" + hex.EncodeToString(sum[:8])
+	}
+	return key
+}
@@ -0,0 +1,215 @@
+package quota
+
+import (
+	"encoding/json"
+	"fmt"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/conf"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/logger"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// etcd 中每条规则一个key: business.quota.<name>
+	WatchPrefix = "business.quota"
+	// 配置文件中规则列表的key
+	ConfigFileKey = "quota"
+
+	// 匹配任意 app_key 或接口
+	Any = "*"
+
+	ModeWait     = "wait"
+	ModeFailFast = "fail"
+)
+
+// 令牌桶规则, 每个 period 补充 limit 次调用额度, 最多积攒 burst 次
+type Rule struct {
+	AppKey  string
+	Method  string
+	Limit   int
+	Period  time.Duration
+	Burst   int
+	Mode    string
+	MaxWait time.Duration
+	// 每个 session(卖家授权)单独一个桶, 只作用于带 session 的调用
+	PerSession bool
+}
+
+// etcd/配置文件中的规则, 时间为 time.ParseDuration 格式
+type ruleConfig struct {
+	AppKey     string `json:"app_key"`
+	Method     string `json:"method"`
+	Limit      int    `json:"limit"`
+	Period     string `json:"period"`
+	Burst      int    `json:"burst"`
+	Mode       string `json:"mode"`
+	MaxWait    string `json:"max_wait"`
+	PerSession bool   `json:"per_session"`
+}
+
+func (rc ruleConfig) rule() (*Rule, error) {
+	r := &Rule{
+		AppKey: rc.AppKey,
+		Method: rc.Method,
+		Limit:  rc.Limit,
+		Period: time.Second,
+		Burst:  rc.Burst,
+		Mode:   strings.ToLower(rc.Mode),
+	}
+	r.PerSession = rc.PerSession
+	if r.AppKey == "" {
+		r.AppKey = Any
+	}
+	if r.Method == "" {
+		r.Method = Any
+	}
+	if r.Limit <= 0 {
+		return nil, fmt.Errorf("quota: rule %s/%s limit must be positive", r.AppKey, r.Method)
+	}
+	if rc.Period != "" {
+		d, err := time.ParseDuration(rc.Period)
+		if err != nil || d < time.Millisecond {
+			return nil, fmt.Errorf("quota: rule %s/%s invalid period %q", r.AppKey, r.Method, rc.Period)
+		}
+		r.Period = d
+	}
+	if r.Burst <= 0 {
+		r.Burst = r.Limit
+	}
+	switch r.Mode {
+	case "":
+		r.Mode = ModeWait
+	case ModeWait, ModeFailFast:
+	default:
+		return nil, fmt.Errorf("quota: rule %s/%s invalid mode %q", r.AppKey, r.Method, rc.Mode)
+	}
+	r.MaxWait = r.Period
+	if rc.MaxWait != "" {
+		d, err := time.ParseDuration(rc.MaxWait)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("quota: rule %s/%s invalid max_wait %q", r.AppKey, r.Method, rc.MaxWait)
+		}
+		r.MaxWait = d
+	}
+	return r, nil
+}
+
+func ruleKey(appKey, method string, perSession bool) string {
+	return appKey + "|" + method + "|" + strconv.FormatBool(perSession)
+}
+
+var (
+	// 规则名 -> 规则, 变更后重建索引
+	rulesMu sync.Mutex
+	named   = map[string]*Rule{}
+	index   atomic.Value
+)
+
+func init() {
+	index.Store(map[string]*Rule{})
+}
+
+func rebuild() {
+	idx := make(map[string]*Rule, len(named))
+	for _, r := range named {
+		idx[ruleKey(r.AppKey, r.Method, r.PerSession)] = r
+	}
+	index.Store(idx)
+}
+
+func store(name string, r *Rule) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	named[name] = r
+	rebuild()
+	logger.Infof("quota rule %s stored: app_key=%s method=%s limit=%d/%s burst=%d mode=%s max_wait=%s per_session=%t",
+		name, r.AppKey, r.Method, r.Limit, r.Period, r.Burst, r.Mode, r.MaxWait, r.PerSession)
+}
+
+func remove(name string) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	delete(named, name)
+	rebuild()
+	logger.Infof("quota rule %s deleted", name)
+}
+
+// 返回调用需要同时满足的全部规则, 未配置时不限流
+// 接口级和应用级(method 为 *)各取一条, 精确 app_key 优先于通配; 应用级额度是淘宝封禁的依据, 不会被接口级规则绕过
+// 应用和 session 两个维度分别匹配
+func MatchAll(appKey, method string) []*Rule {
+	idx := index.Load().(map[string]*Rule)
+	var rules []*Rule
+	for _, perSession := range []bool{false, true} {
+		levels := [][]string{
+			{ruleKey(appKey, method, perSession), ruleKey(Any, method, perSession)},
+			{ruleKey(appKey, Any, perSession), ruleKey(Any, Any, perSession)},
+		}
+		if method == Any {
+			levels = levels[1:]
+		}
+		for _, keys := range levels {
+			for _, k := range keys {
+				if r, ok := idx[k]; ok {
+					rules = append(rules, r)
+					break
+				}
+			}
+		}
+	}
+	return rules
+}
+
+// 从配置文件加载规则列表
+func LoadFromConfigFile() error {
+	v := conf.GetFormConfigFile(ConfigFileKey)
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("quota: marshal %s: %v", ConfigFileKey, err)
+	}
+	var rcs []ruleConfig
+	if err := json.Unmarshal(data, &rcs); err != nil {
+		return fmt.Errorf("quota: unmarshal %s: %v", ConfigFileKey, err)
+	}
+	for i, rc := range rcs {
+		r, err := rc.rule()
+		if err != nil {
+			return err
+		}
+		store(fmt.Sprintf("file.%d", i), r)
+	}
+	return nil
+}
+
+// etcd 规则监听, value 为单条规则的 json
+type Event struct {
+	Prefix string
+}
+
+func (e *Event) GetPrefix() string {
+	return e.Prefix
+}
+
+func (e *Event) OnPut(key []byte, value []byte) error {
+	var rc ruleConfig
+	if err := json.Unmarshal(value, &rc); err != nil {
+		return fmt.Errorf("quota: unmarshal %s: %v", key, err)
+	}
+	r, err := rc.rule()
+	if err != nil {
+		return err
+	}
+	store(string(key), r)
+	return nil
+}
+
+func (e *Event) OnDelete(key []byte) error {
+	remove(string(key))
+	return nil
+}
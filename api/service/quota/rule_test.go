@@ -0,0 +1,82 @@
+package quota
+
+import (
+	"strings"
+	"testing"
+)
+
+func storeTestRule(t *testing.T, name string, rc ruleConfig) {
+	t.Helper()
+	r, err := rc.rule()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store(name, r)
+	t.Cleanup(func() { remove(name) })
+}
+
+func ruleNames(rules []*Rule) []string {
+	var names []string
+	for _, r := range rules {
+		name := r.AppKey + "/" + r.Method
+		if r.PerSession {
+			name += "/session"
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestMatchAll(t *testing.T) {
+	storeTestRule(t, "any", ruleConfig{Limit: 100})
+	storeTestRule(t, "app", ruleConfig{AppKey: "app", Limit: 50})
+	storeTestRule(t, "app-method", ruleConfig{AppKey: "app", Method: "taobao.item.seller.get", Limit: 5})
+	storeTestRule(t, "any-method", ruleConfig{Method: "taobao.trades.sold.get", Limit: 10})
+	storeTestRule(t, "session", ruleConfig{AppKey: "app", Limit: 2, PerSession: true})
+
+	cases := []struct {
+		appKey, method string
+		want           []string
+	}{
+		// 接口级规则不能绕过应用级额度
+		{"app", "taobao.item.seller.get", []string{"app/taobao.item.seller.get", "app/*", "app/*/session"}},
+		{"app", "taobao.trades.sold.get", []string{"*/taobao.trades.sold.get", "app/*", "app/*/session"}},
+		{"app", "taobao.itemcats.get", []string{"app/*", "app/*/session"}},
+		{"other", "taobao.item.seller.get", []string{"*/*"}},
+		{"other", "taobao.trades.sold.get", []string{"*/taobao.trades.sold.get", "*/*"}},
+	}
+	for _, c := range cases {
+		got := ruleNames(MatchAll(c.appKey, c.method))
+		if len(got) != len(c.want) {
+			t.Errorf("MatchAll(%s, %s) = %v, want %v", c.appKey, c.method, got, c.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("MatchAll(%s, %s) = %v, want %v", c.appKey, c.method, got, c.want)
+				break
+			}
+		}
+	}
+}
+
+func TestMatchAllNoRules(t *testing.T) {
+	if rules := MatchAll("app", "taobao.item.seller.get"); len(rules) != 0 {
+		t.Fatalf("MatchAll = %v, want none", ruleNames(rules))
+	}
+}
+
+func TestBucketKey(t *testing.T) {
+	r := &Rule{AppKey: "app", Method: Any}
+	if got := bucketKey("app", "secret-session", r); got != "tbtool:quota:{app}:*" {
+		t.Errorf("app bucket = %s", got)
+	}
+	r.PerSession = true
+	a, b := bucketKey("app", "secret-session", r), bucketKey("app", "other-session", r)
+	if a == b {
+		t.Error("sessions share a bucket")
+	}
+	if strings.Contains(a, "secret-session") {
+		t.Errorf("session bucket leaks session: %s", a)
+	}
+}
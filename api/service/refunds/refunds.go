@@ -0,0 +1,122 @@
+package refunds
+
+import (
+	"context"
+	"tbTool/api/service/top"
+	"time"
+)
+
+const (
+	MethodRefundsReceiveGet = "taobao.refunds.receive.get"
+
+	RefundFields = "refund_id,tid,oid,title,buyer_nick,seller_nick,total_fee,refund_fee,payment,status,order_status," +
+		"good_status,has_good_return,created,modified,num_iid,sku,num,reason,desc,refund_phase"
+
+	DefaultPageSize = 40
+	MaxPageSize     = 100
+)
+
+type Refund struct {
+	RefundID      int64  `json:"refund_id"`
+	Tid           int64  `json:"tid"`
+	Oid           int64  `json:"oid"`
+	Title         string `json:"title"`
+	BuyerNick     string `json:"buyer_nick"`
+	SellerNick    string `json:"seller_nick"`
+	TotalFee      string `json:"total_fee"`
+	RefundFee     string `json:"refund_fee"`
+	Payment       string `json:"payment"`
+	Status        string `json:"status"`
+	OrderStatus   string `json:"order_status"`
+	GoodStatus    string `json:"good_status"`
+	HasGoodReturn bool   `json:"has_good_return"`
+	Created       string `json:"created"`
+	Modified      string `json:"modified"`
+	NumIid        int64  `json:"num_iid"`
+	Sku           string `json:"sku,omitempty"`
+	Num           int64  `json:"num"`
+	Reason        string `json:"reason,omitempty"`
+	Desc          string `json:"desc,omitempty"`
+	RefundPhase   string `json:"refund_phase,omitempty"`
+}
+
+type RefundsReceiveGetResponse struct {
+	Refunds struct {
+		Refund []Refund `json:"refund"`
+	} `json:"refunds"`
+	TotalResults int  `json:"total_results"`
+	HasNext      bool `json:"has_next"`
+}
+
+type RefundQuery struct {
+	// 退款状态, 如 WAIT_SELLER_AGREE, 为空时查询全部
+	Status        string
+	StartModified time.Time
+	EndModified   time.Time
+	PageNo        int
+	PageSize      int
+}
+
+type RefundsPage struct {
+	Refunds      []Refund `json:"refunds"`
+	PageNo       int      `json:"page_no"`
+	PageSize     int      `json:"page_size"`
+	TotalResults int      `json:"total_results"`
+	HasNext      bool     `json:"has_next"`
+}
+
+type RefundService interface {
+	// 查询卖家收到的退款列表
+	RefundsReceiveGet(ctx context.Context, session string, q RefundQuery) (*RefundsPage, error)
+}
+
+type RefundServiceImpl struct {
+	cli top.Caller
+}
+
+func NewRefundServiceImpl(cli top.Caller) RefundService {
+	return &RefundServiceImpl{
+		cli: cli,
+	}
+}
+
+func (rs *RefundServiceImpl) RefundsReceiveGet(ctx context.Context, session string, q RefundQuery) (*RefundsPage, error) {
+	if q.PageSize <= 0 {
+		q.PageSize = DefaultPageSize
+	}
+	if q.PageSize > MaxPageSize {
+		q.PageSize = MaxPageSize
+	}
+	if q.PageNo <= 0 {
+		q.PageNo = 1
+	}
+
+	params := top.Params{}.
+		Set("session", session).
+		Set("fields", RefundFields).
+		Set("page_no", q.PageNo).
+		Set("page_size", q.PageSize).
+		Set("use_has_next", true)
+	if q.Status != "" {
+		params.Set("status", q.Status)
+	}
+	if !q.StartModified.IsZero() {
+		params.Set("start_modified", q.StartModified)
+	}
+	if !q.EndModified.IsZero() {
+		params.Set("end_modified", q.EndModified)
+	}
+
+	var resp RefundsReceiveGetResponse
+	if err := rs.cli.Call(ctx, MethodRefundsReceiveGet, params, &resp); err != nil {
+		return nil, err
+	}
+
+	return &RefundsPage{
+		Refunds:      resp.Refunds.Refund,
+		PageNo:       q.PageNo,
+		PageSize:     q.PageSize,
+		TotalResults: resp.TotalResults,
+		HasNext:      resp.HasNext,
+	}, nil
+}
@@ -0,0 +1,157 @@
+package shop
+
+import (
+	"fmt"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/algs"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/conf"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/logger"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"tbTool/api/service/top"
+	"time"
+)
+
+const (
+	// 默认应用配置, etcd 中每项一个key: business.top.app_key
+	DefaultsWatchPrefix = "business.top"
+	// 配置文件中默认应用配置的key
+	DefaultsConfigFileKey = "top"
+)
+
+var (
+	defaults atomic.Value
+
+	// etcd 逐个key推送, 先合并再整体替换
+	defaultsMu  sync.Mutex
+	defaultsRaw = map[string]string{}
+)
+
+func init() {
+	defaults.Store(top.Config{})
+}
+
+// 店铺未配置的 app_key/secret/网关/批量网关/签名方式及超时重试沿用默认应用配置
+func Defaults() top.Config {
+	return defaults.Load().(top.Config)
+}
+
+func storeDefaults(cfg top.Config) {
+	defaults.Store(cfg)
+	logger.Infof("top defaults stored: %s", cfg)
+
+	// 继承默认配置的店铺需要重建客户端
+	// 持锁期间店铺不会被删除或更新, 重建用的都是当前的原始配置
+	shopsMu.Lock()
+	Range(func(s *Shop) bool {
+		if err := store(s.raw); err != nil {
+			logger.Errorf("rebuild shop %s with new defaults error: %v", s.ID, err)
+		}
+		return true
+	})
+	shopsMu.Unlock()
+	notifyWatchers()
+}
+
+func defaultsFromMap(m map[string]string) (top.Config, error) {
+	cfg := top.Config{
+		AppKey:       m["app_key"],
+		AppSecret:    m["app_secret"],
+		Gateway:      m["gateway"],
+		BatchGateway: m["batch_gateway"],
+		SignMethod:   m["sign_method"],
+		HTTPMethod:   strings.ToUpper(m["http_method"]),
+	}
+	if v := m["timeout"]; v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return cfg, fmt.Errorf("shop: invalid top.timeout %q: %v", v, err)
+		}
+		cfg.Timeout = d
+	}
+	if v := m["retries"]; v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return cfg, fmt.Errorf("shop: invalid top.retries %q: %v", v, err)
+		}
+		cfg.Retries = n
+	}
+	return cfg, nil
+}
+
+// 从配置文件加载默认应用配置
+func LoadDefaultsFromConfigFile() error {
+	m := conf.GetStringMapStringFormConfigFile(DefaultsConfigFileKey)
+	if len(m) == 0 {
+		return nil
+	}
+
+	defaultsMu.Lock()
+	defer defaultsMu.Unlock()
+
+	cfg, err := defaultsFromMap(m)
+	if err != nil {
+		return err
+	}
+	defaultsRaw = m
+	storeDefaults(cfg)
+	return nil
+}
+
+// etcd 默认应用配置监听, 变更后原子替换
+type DefaultsEvent struct {
+	Prefix string
+}
+
+func (e *DefaultsEvent) GetPrefix() string {
+	return e.Prefix
+}
+
+func (e *DefaultsEvent) OnPut(key []byte, value []byte) error {
+	return e.update(string(key), string(value), false)
+}
+
+func (e *DefaultsEvent) OnDelete(key []byte) error {
+	return e.update(string(key), "", true)
+}
+
+func (e *DefaultsEvent) update(key, value string, del bool) error {
+	field := strings.TrimPrefix(strings.TrimPrefix(key, e.Prefix), ".")
+
+	defaultsMu.Lock()
+	defer defaultsMu.Unlock()
+
+	next := make(map[string]string, len(defaultsRaw)+1)
+	for k, v := range defaultsRaw {
+		next[k] = v
+	}
+	if del {
+		delete(next, field)
+	} else {
+		next[field] = strings.TrimSpace(value)
+	}
+
+	cfg, err := defaultsFromMap(next)
+	if err != nil {
+		return err
+	}
+	defaultsRaw = next
+	storeDefaults(cfg)
+	return nil
+}
+
+// 店铺配置与默认配置合并
+func mergeDefaults(s *Shop) top.Config {
+	d := Defaults()
+	return top.Config{
+		AppKey:       algs.FirstNotEmpty(s.AppKey, d.AppKey),
+		AppSecret:    algs.FirstNotEmpty(s.AppSecret, d.AppSecret),
+		Gateway:      algs.FirstNotEmpty(s.Gateway, d.Gateway),
+		BatchGateway: algs.FirstNotEmpty(s.BatchGateway, d.BatchGateway),
+		SignMethod:   algs.FirstNotEmpty(s.SignMethod, d.SignMethod),
+		HTTPMethod:   d.HTTPMethod,
+		Timeout:      d.Timeout,
+		Retries:      d.Retries,
+	}
+}
@@ -0,0 +1,227 @@
+package shop
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/conf"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/logger"
+	"strings"
+	"sync"
+	"tbTool/api/service/top"
+	"tbTool/pkg/request"
+)
+
+const (
+	// etcd 中店铺配置的前缀, 每个店铺一个key: business.shops.<shopId>
+	WatchPrefix = "business.shops"
+	// 配置文件中店铺配置的key
+	ConfigFileKey = "shops"
+)
+
+var (
+	ErrMissingShop = errors.New("shop: missing shop id")
+	ErrUnknownShop = errors.New("shop: unknown shop")
+
+	shops sync.Map
+	// 串行化店铺的写入和默认配置变更后的重建, 读取不加锁
+	shopsMu sync.Mutex
+)
+
+type Shop struct {
+	ID        string `json:"id" mapstructure:"id"`
+	Name      string `json:"name" mapstructure:"name"`
+	AppKey    string `json:"app_key" mapstructure:"app_key"`
+	AppSecret string `json:"app_secret" mapstructure:"app_secret"`
+	Gateway   string `json:"gateway" mapstructure:"gateway"`
+	// 批量网关, 店铺和默认配置都未配置时不走批量调用
+	BatchGateway string `json:"batch_gateway" mapstructure:"batch_gateway"`
+	SignMethod   string `json:"sign_method" mapstructure:"sign_method"`
+	// 店铺对应的卖家id, 调用方未传卖家id时用于查找授权
+	SellerID string `json:"seller_id" mapstructure:"seller_id"`
+	// 固定 session, 配置后不再走 OAuth 授权
+	Session string `json:"session" mapstructure:"session"`
+	// 店铺所在平台, 为空时为淘宝
+	Platform string `json:"platform" mapstructure:"platform"`
+
+	client *top.Client
+	// 原始配置, 默认配置变更时据此重新合并
+	raw *Shop
+}
+
+func (s *Shop) Client() *top.Client {
+	return s.client
+}
+
+// 日志输出时隐藏 secret 和 session
+func (s *Shop) String() string {
+	return fmt.Sprintf("{id:%s name:%s platform:%s app_key:%s app_secret:%s gateway:%s batch_gateway:%s seller_id:%s session:%s}",
+		s.ID, s.Name, s.Platform, s.AppKey, top.Mask(s.AppSecret), s.Gateway, s.BatchGateway, s.SellerID, top.Mask(s.Session))
+}
+
+func (s *Shop) validate() error {
+	if s.ID == "" || s.AppKey == "" || s.AppSecret == "" || s.Gateway == "" {
+		return fmt.Errorf("shop: %q requires id, app_key, app_secret and gateway", s.ID)
+	}
+	return nil
+}
+
+// 新增或替换店铺配置, 未配置的凭证沿用默认应用配置
+func Store(raw *Shop) error {
+	shopsMu.Lock()
+	err := store(raw)
+	shopsMu.Unlock()
+	if err != nil {
+		return err
+	}
+	notifyWatchers()
+	return nil
+}
+
+// 调用方已持有 shopsMu
+func store(raw *Shop) error {
+	cfg := mergeDefaults(raw)
+	s := &Shop{
+		ID:           raw.ID,
+		Name:         raw.Name,
+		AppKey:       cfg.AppKey,
+		AppSecret:    cfg.AppSecret,
+		Gateway:      cfg.Gateway,
+		BatchGateway: cfg.BatchGateway,
+		SignMethod:   cfg.SignMethod,
+		SellerID:     raw.SellerID,
+		Session:      raw.Session,
+		Platform:     raw.Platform,
+		raw:          raw,
+	}
+	if err := s.validate(); err != nil {
+		return err
+	}
+	s.client = top.NewClient(cfg)
+	shops.Store(s.ID, s)
+	logger.Infof("shop stored: %s", s)
+	return nil
+}
+
+func Delete(id string) {
+	shopsMu.Lock()
+	shops.Delete(id)
+	shopsMu.Unlock()
+	logger.Infof("shop %s deleted", id)
+	notifyWatchers()
+}
+
+func Get(id string) (*Shop, error) {
+	if id == "" {
+		return nil, ErrMissingShop
+	}
+	v, ok := shops.Load(id)
+	if !ok {
+		return nil, ErrUnknownShop
+	}
+	return v.(*Shop), nil
+}
+
+func Range(f func(s *Shop) bool) {
+	shops.Range(func(_, v interface{}) bool {
+		return f(v.(*Shop))
+	})
+}
+
+// 从配置文件加载店铺
+func LoadFromConfigFile() error {
+	sub := conf.SubFormConfigFile(ConfigFileKey)
+	if sub == nil {
+		return nil
+	}
+	for id := range sub.AllSettings() {
+		s := &Shop{}
+		if err := sub.UnmarshalKey(id, s); err != nil {
+			return fmt.Errorf("shop: unmarshal %s: %v", id, err)
+		}
+		if s.ID == "" {
+			s.ID = id
+		}
+		if err := Store(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// etcd 店铺配置监听, 增删店铺无需重启
+type Event struct {
+	Prefix string
+}
+
+func (e *Event) GetPrefix() string {
+	return e.Prefix
+}
+
+func (e *Event) OnPut(key []byte, value []byte) error {
+	s := &Shop{}
+	if err := json.Unmarshal(value, s); err != nil {
+		return fmt.Errorf("shop: json unmarshal %s: %v", string(key), err)
+	}
+	if s.ID == "" {
+		s.ID = e.shopID(key)
+	}
+	return Store(s)
+}
+
+func (e *Event) OnDelete(key []byte) error {
+	Delete(e.shopID(key))
+	return nil
+}
+
+// business.shops.<shopId> -> <shopId>
+func (e *Event) shopID(key []byte) string {
+	return strings.TrimPrefix(strings.TrimPrefix(string(key), e.Prefix), ".")
+}
+
+type ctxKey struct{}
+
+func NewContext(ctx context.Context, s *Shop) context.Context {
+	return context.WithValue(ctx, ctxKey{}, s)
+}
+
+func FromContext(ctx context.Context) (*Shop, bool) {
+	s, ok := ctx.Value(ctxKey{}).(*Shop)
+	return s, ok
+}
+
+// 按上下文中的店铺选择凭证发起 TOP 调用
+type Caller struct{}
+
+func NewCaller() top.Caller {
+	return &Caller{}
+}
+
+func (sc *Caller) Call(ctx context.Context, method string, params top.Params, out interface{}) error {
+	s, ok := FromContext(ctx)
+	if !ok {
+		return ErrMissingShop
+	}
+	return s.Client().Call(ctx, method, params, out)
+}
+
+func (sc *Caller) Upload(ctx context.Context, method string, params top.Params, files []request.File, out interface{}) error {
+	s, ok := FromContext(ctx)
+	if !ok {
+		return ErrMissingShop
+	}
+	return s.Client().Upload(ctx, method, params, files, out)
+}
+
+func (sc *Caller) Batch(ctx context.Context, calls []top.BatchCall) []error {
+	s, ok := FromContext(ctx)
+	if !ok {
+		errs := make([]error, len(calls))
+		for i := range errs {
+			errs[i] = ErrMissingShop
+		}
+		return errs
+	}
+	return s.Client().Batch(ctx, calls)
+}
@@ -0,0 +1,36 @@
+package shop
+
+import "sync"
+
+var (
+	watchersMu sync.Mutex
+	watchers   = map[int]func(){}
+	nextWatch  int
+)
+
+// 店铺新增、更新、删除或默认配置变更后回调 fn, 返回取消函数
+// 回调在变更方的 goroutine 中执行, 不持有店铺锁, 可在其中调用 Range/Get
+func Watch(fn func()) (cancel func()) {
+	watchersMu.Lock()
+	defer watchersMu.Unlock()
+	id := nextWatch
+	nextWatch++
+	watchers[id] = fn
+	return func() {
+		watchersMu.Lock()
+		defer watchersMu.Unlock()
+		delete(watchers, id)
+	}
+}
+
+func notifyWatchers() {
+	watchersMu.Lock()
+	fns := make([]func(), 0, len(watchers))
+	for _, fn := range watchers {
+		fns = append(fns, fn)
+	}
+	watchersMu.Unlock()
+	for _, fn := range fns {
+		fn()
+	}
+}
@@ -0,0 +1,75 @@
+package snapshots
+
+import (
+	"context"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/conf"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/grpool"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/logger"
+	"sync"
+	"tbTool/api/service/shop"
+	"tbTool/api/service/top"
+	"time"
+)
+
+const (
+	defaultSnapshotInterval = time.Hour
+	snapshotTimeout         = 10 * time.Minute
+)
+
+// 定时为所有店铺拍商品快照
+type SnapshotJob struct {
+	sn   SnapshotService
+	stop chan struct{}
+	once sync.Once
+}
+
+func NewSnapshotJob(sn SnapshotService) *SnapshotJob {
+	return &SnapshotJob{
+		sn:   sn,
+		stop: make(chan struct{}),
+	}
+}
+
+func (j *SnapshotJob) Start() error {
+	interval := conf.GetDurationFormConfigFile("items.snapshot_interval")
+	if interval <= 0 {
+		interval = defaultSnapshotInterval
+	}
+
+	return grpool.Submit(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-j.stop:
+				return
+			case <-ticker.C:
+				j.runOnce()
+			}
+		}
+	})
+}
+
+func (j *SnapshotJob) Stop() error {
+	j.once.Do(func() { close(j.stop) })
+	return nil
+}
+
+func (j *SnapshotJob) runOnce() {
+	shop.Range(func(s *shop.Shop) bool {
+		ctx := top.WithQuotaMode(shop.NewContext(context.Background(), s), top.QuotaWait)
+		ctx, cancel := context.WithTimeout(ctx, snapshotTimeout)
+		defer cancel()
+
+		result, err := j.sn.Snapshot(ctx)
+		switch err {
+		case nil:
+			logger.Infof("items snapshot shop %s done: %d items, %d changes", s.ID, result.Items, result.Changes)
+		case ErrSnapshotRunning:
+			logger.Debugf("items snapshot shop %s skipped: %v", s.ID, err)
+		default:
+			logger.Errorf("items snapshot shop %s error: %v", s.ID, err)
+		}
+		return true
+	})
+}
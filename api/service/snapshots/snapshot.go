@@ -0,0 +1,156 @@
+package snapshots
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/jinzhu/gorm"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/logger"
+	dredis "gitlab.xfq.com/tech-lab/dionysus/pkg/redis"
+	"tbTool/api/service/items"
+	"tbTool/api/service/shop"
+	"tbTool/api/tools/common"
+	"time"
+)
+
+const (
+	snapshotLockPrefix = "tbtool:items:snapshot:"
+	snapshotLockTTL    = 10 * time.Minute
+)
+
+func (sn *SnapshotServiceImpl) Snapshot(ctx context.Context) (*SnapshotResult, error) {
+	s, ok := shop.FromContext(ctx)
+	if !ok {
+		return nil, shop.ErrMissingShop
+	}
+
+	// 多副本/定时任务互斥
+	rc, err := dredis.GetClient(ctx, common.RedisName)
+	if err != nil {
+		return nil, err
+	}
+	lock, locked, err := common.TryLock(rc, snapshotLockPrefix+s.ID, snapshotLockTTL)
+	if err != nil {
+		return nil, err
+	}
+	if !locked {
+		return nil, ErrSnapshotRunning
+	}
+	defer lock.Release()
+
+	session, err := sn.ss.Session(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	db, err := getDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := sn.is.ItemsOnSaleGet(ctx, session, items.OnSaleQuery{
+		Fields: SnapshotFields,
+		All:    true,
+		Fresh:  true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	list, unconfirmed, err := sn.confirmMissing(ctx, db, s.ID, session, page.Items)
+	if err != nil {
+		return nil, err
+	}
+
+	at := time.Now()
+	changes, err := saveSnapshot(db, s.ID, list, unconfirmed, at)
+	if err != nil {
+		return nil, err
+	}
+	if sn.obs != nil {
+		sn.obs.OnSnapshot(ctx, s.ID, list, toChanges(changes))
+	}
+	return &SnapshotResult{ShopID: s.ID, At: at, Items: len(list), Changes: len(changes)}, nil
+}
+
+// 分页拉取期间商品上下架会导致翻页错位, 商品从列表中"消失"并不代表已下架
+// 上次在售而本次未出现的商品逐个用 items.seller.list.get 确认:
+// 仍在售的补回列表, 确认失败的返回在 unconfirmed 中, 本次不记下架
+func (sn *SnapshotServiceImpl) confirmMissing(ctx context.Context, db *gorm.DB, shopID, session string,
+	list []items.Item) ([]items.Item, map[int64]bool, error) {
+	prev, err := onSaleIDs(db, shopID)
+	if err != nil {
+		return nil, nil, err
+	}
+	seen := make(map[int64]bool, len(list))
+	for i := range list {
+		seen[list[i].NumIid] = true
+	}
+	var missing []int64
+	for _, numIid := range prev {
+		if !seen[numIid] {
+			missing = append(missing, numIid)
+		}
+	}
+	if len(missing) == 0 {
+		return list, nil, nil
+	}
+
+	details, err := sn.is.ItemsSellerListGet(ctx, session, missing, SnapshotFields)
+	if err != nil {
+		logger.Warnf("snapshot %s confirm %d missing items error: %v", shopID, len(missing), err)
+		unconfirmed := make(map[int64]bool, len(missing))
+		for _, numIid := range missing {
+			unconfirmed[numIid] = true
+		}
+		return list, unconfirmed, nil
+	}
+	// 未返回的商品已删除, 与下架一样处理
+	for i := range details {
+		if details[i].ApproveStatus == items.ApproveStatusOnSale {
+			list = append(list, details[i].Item)
+		}
+	}
+	return list, nil, nil
+}
+
+func (sn *SnapshotServiceImpl) History(ctx context.Context, q HistoryQuery) ([]Change, error) {
+	s, ok := shop.FromContext(ctx)
+	if !ok {
+		return nil, shop.ErrMissingShop
+	}
+	if q.Limit <= 0 {
+		q.Limit = DefaultHistoryLimit
+	}
+	if q.Limit > MaxHistoryLimit {
+		q.Limit = MaxHistoryLimit
+	}
+
+	db, err := getDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := queryChanges(db, s.ID, q)
+	if err != nil {
+		return nil, err
+	}
+
+	return toChanges(rows), nil
+}
+
+func toChanges(rows []ItemChange) []Change {
+	changes := make([]Change, len(rows))
+	for i, r := range rows {
+		changes[i] = Change{
+			NumIid:    r.NumIid,
+			Field:     r.Field,
+			OldValue:  r.OldValue,
+			NewValue:  r.NewValue,
+			ChangedAt: r.ChangedAt,
+		}
+	}
+	return changes
+}
+
+func rawJSON(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
@@ -0,0 +1,71 @@
+package snapshots
+
+import (
+	"context"
+	"errors"
+	"tbTool/api/service/items"
+	"tbTool/api/service/oauth"
+	"time"
+)
+
+const (
+	// 快照拉取的扩展字段
+	SnapshotFields = "num_iid,title,price,num,outer_id,pic_url,cid,approve_status,list_time,delist_time,modified"
+
+	// 历史查询单次最多返回的条数
+	DefaultHistoryLimit = 200
+	MaxHistoryLimit     = 1000
+)
+
+var ErrSnapshotRunning = errors.New("snapshots: snapshot is already running for this shop")
+
+// 一次快照的结果
+type SnapshotResult struct {
+	ShopID  string    `json:"shop_id"`
+	At      time.Time `json:"at"`
+	Items   int       `json:"items"`
+	Changes int       `json:"changes"`
+}
+
+type HistoryQuery struct {
+	NumIid int64
+	// 为空时返回全部字段
+	Field string
+	From  time.Time
+	To    time.Time
+	Limit int
+}
+
+type Change struct {
+	NumIid    int64     `json:"num_iid"`
+	Field     string    `json:"field"`
+	OldValue  string    `json:"old_value"`
+	NewValue  string    `json:"new_value"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+type SnapshotService interface {
+	// 拉取当前店铺全部出售中商品, 与上次快照比对并记录变更
+	Snapshot(ctx context.Context) (*SnapshotResult, error)
+	// 按商品和时间范围查询当前店铺的变更记录, 按时间倒序
+	History(ctx context.Context, q HistoryQuery) ([]Change, error)
+}
+
+// 快照完成后接收本次的出售中商品和字段变更, 如告警规则评估
+type Observer interface {
+	OnSnapshot(ctx context.Context, shopID string, list []items.Item, changes []Change)
+}
+
+type SnapshotServiceImpl struct {
+	is  items.ItemService
+	ss  oauth.SessionService
+	obs Observer
+}
+
+func NewSnapshotServiceImpl(is items.ItemService, ss oauth.SessionService, obs Observer) SnapshotService {
+	return &SnapshotServiceImpl{
+		is:  is,
+		ss:  ss,
+		obs: obs,
+	}
+}
@@ -0,0 +1,195 @@
+package snapshots
+
+import (
+	"context"
+	"github.com/jinzhu/gorm"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/orm"
+	"strconv"
+	"sync"
+	"tbTool/api/service/items"
+	"tbTool/api/tools/common"
+	"time"
+)
+
+// 每个商品最近一次快照
+type ItemSnapshot struct {
+	ShopID        string `gorm:"primary_key;type:varchar(64)"`
+	NumIid        int64  `gorm:"primary_key;auto_increment:false"`
+	Title         string `gorm:"type:varchar(255)"`
+	Price         string `gorm:"type:varchar(32)"`
+	Num           int64
+	OuterID       string `gorm:"type:varchar(128)"`
+	PicURL        string `gorm:"type:varchar(512)"`
+	Cid           int64
+	ApproveStatus string `gorm:"type:varchar(32)"`
+	// 不在出售中列表时置为 false, 保留最后一次的值
+	OnSale     bool
+	Raw        string `gorm:"type:text"`
+	SnapshotAt time.Time
+}
+
+func (ItemSnapshot) TableName() string {
+	return "tb_item_snapshot"
+}
+
+// 字段级变更记录
+type ItemChange struct {
+	ID        int64     `gorm:"primary_key"`
+	ShopID    string    `gorm:"type:varchar(64);index:idx_shop_item_time"`
+	NumIid    int64     `gorm:"index:idx_shop_item_time"`
+	Field     string    `gorm:"type:varchar(32)"`
+	OldValue  string    `gorm:"type:text"`
+	NewValue  string    `gorm:"type:text"`
+	ChangedAt time.Time `gorm:"index:idx_shop_item_time"`
+}
+
+func (ItemChange) TableName() string {
+	return "tb_item_change"
+}
+
+var (
+	migrateMu sync.Mutex
+	migrated  bool
+)
+
+func getDB(ctx context.Context) (*gorm.DB, error) {
+	cli, err := orm.GetClient(ctx, common.OrmName)
+	if err != nil {
+		return nil, err
+	}
+
+	migrateMu.Lock()
+	defer migrateMu.Unlock()
+	if !migrated {
+		if err := cli.AutoMigrate(&ItemSnapshot{}, &ItemChange{}).Error; err != nil {
+			return nil, err
+		}
+		migrated = true
+	}
+	return cli.DB, nil
+}
+
+func toSnapshot(shopID string, it *items.Item, at time.Time) *ItemSnapshot {
+	return &ItemSnapshot{
+		ShopID:        shopID,
+		NumIid:        it.NumIid,
+		Title:         it.Title,
+		Price:         it.Price,
+		Num:           it.Num,
+		OuterID:       it.OuterID,
+		PicURL:        it.PicURL,
+		Cid:           it.Cid,
+		ApproveStatus: it.ApproveStatus,
+		OnSale:        true,
+		Raw:           rawJSON(it),
+		SnapshotAt:    at,
+	}
+}
+
+// 参与比对的字段, 顺序即变更记录的写入顺序
+var trackedFields = []struct {
+	name  string
+	value func(s *ItemSnapshot) string
+}{
+	{"title", func(s *ItemSnapshot) string { return s.Title }},
+	{"price", func(s *ItemSnapshot) string { return s.Price }},
+	{"num", func(s *ItemSnapshot) string { return strconv.FormatInt(s.Num, 10) }},
+	{"outer_id", func(s *ItemSnapshot) string { return s.OuterID }},
+	{"pic_url", func(s *ItemSnapshot) string { return s.PicURL }},
+	{"cid", func(s *ItemSnapshot) string { return strconv.FormatInt(s.Cid, 10) }},
+	{"approve_status", func(s *ItemSnapshot) string { return s.ApproveStatus }},
+	{"on_sale", func(s *ItemSnapshot) string { return strconv.FormatBool(s.OnSale) }},
+}
+
+func diff(prev, next *ItemSnapshot, at time.Time) []ItemChange {
+	var changes []ItemChange
+	for _, f := range trackedFields {
+		old, cur := f.value(prev), f.value(next)
+		if old == cur {
+			continue
+		}
+		changes = append(changes, ItemChange{
+			ShopID:    next.ShopID,
+			NumIid:    next.NumIid,
+			Field:     f.name,
+			OldValue:  old,
+			NewValue:  cur,
+			ChangedAt: at,
+		})
+	}
+	return changes
+}
+
+// 上一次快照中仍在售的商品
+func onSaleIDs(db *gorm.DB, shopID string) ([]int64, error) {
+	var ids []int64
+	err := db.Model(&ItemSnapshot{}).Where("shop_id = ? AND on_sale = ?", shopID, true).Pluck("num_iid", &ids).Error
+	return ids, err
+}
+
+// 首次出现的商品只记快照不记变更; 从出售中列表消失的商品记一条 on_sale 变更
+// unconfirmed 中的商品未能确认是否下架, 保留上一次快照
+func saveSnapshot(db *gorm.DB, shopID string, list []items.Item, unconfirmed map[int64]bool, at time.Time) ([]ItemChange, error) {
+	var saved []ItemChange
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var prevList []ItemSnapshot
+		if err := tx.Where("shop_id = ?", shopID).Find(&prevList).Error; err != nil {
+			return err
+		}
+		prev := make(map[int64]*ItemSnapshot, len(prevList))
+		for i := range prevList {
+			prev[prevList[i].NumIid] = &prevList[i]
+		}
+
+		var changes []ItemChange
+		seen := make(map[int64]bool, len(list))
+		for i := range list {
+			next := toSnapshot(shopID, &list[i], at)
+			seen[next.NumIid] = true
+			if p, ok := prev[next.NumIid]; ok {
+				changes = append(changes, diff(p, next, at)...)
+			}
+			if err := tx.Save(next).Error; err != nil {
+				return err
+			}
+		}
+		for numIid, p := range prev {
+			if seen[numIid] || !p.OnSale || unconfirmed[numIid] {
+				continue
+			}
+			next := *p
+			next.OnSale = false
+			next.SnapshotAt = at
+			changes = append(changes, diff(p, &next, at)...)
+			if err := tx.Save(&next).Error; err != nil {
+				return err
+			}
+		}
+
+		for i := range changes {
+			if err := tx.Create(&changes[i]).Error; err != nil {
+				return err
+			}
+		}
+		saved = changes
+		return nil
+	})
+	return saved, err
+}
+
+func queryChanges(db *gorm.DB, shopID string, q HistoryQuery) ([]ItemChange, error) {
+	scope := db.Where("shop_id = ? AND num_iid = ?", shopID, q.NumIid)
+	if q.Field != "" {
+		scope = scope.Where("field = ?", q.Field)
+	}
+	if !q.From.IsZero() {
+		scope = scope.Where("changed_at >= ?", q.From)
+	}
+	if !q.To.IsZero() {
+		scope = scope.Where("changed_at <= ?", q.To)
+	}
+
+	var rows []ItemChange
+	err := scope.Order("changed_at DESC, id DESC").Limit(q.Limit).Find(&rows).Error
+	return rows, err
+}
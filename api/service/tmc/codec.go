@@ -0,0 +1,275 @@
+package tmc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+// TMC 二进制协议, 小端序
+// [protocolVersion:1][messageType:1]{[headerType:2][headerValue]...}[EndOfHeaders:2]
+const ProtocolVersion = 2
+
+const (
+	MessageTypeConnect    byte = 0
+	MessageTypeConnectAck byte = 1
+	MessageTypeSend       byte = 2
+	MessageTypeSendAck    byte = 3
+)
+
+const (
+	headerEndOfHeaders int16 = 0
+	headerCustom       int16 = 1
+	headerStatusCode   int16 = 2
+	headerStatusPhrase int16 = 3
+	headerFlag         int16 = 4
+	headerToken        int16 = 5
+)
+
+const (
+	valueVoid      byte = 0
+	valueString    byte = 1
+	valueByte      byte = 2
+	valueInt16     byte = 3
+	valueInt32     byte = 4
+	valueInt64     byte = 5
+	valueDate      byte = 6
+	valueByteArray byte = 7
+)
+
+// 自定义头 __kind 的取值
+const (
+	KindNone        int32 = 0
+	KindPullRequest int32 = 1
+	KindConfirm     int32 = 2
+	KindData        int32 = 3
+)
+
+type Message struct {
+	ProtocolVersion byte
+	MessageType     byte
+	StatusCode      int32
+	StatusPhrase    string
+	Flag            int32
+	Token           string
+	Content         map[string]interface{}
+}
+
+func (m *Message) String(key string) string {
+	s, _ := m.Content[key].(string)
+	return s
+}
+
+func (m *Message) Int64(key string) int64 {
+	switch v := m.Content[key].(type) {
+	case int64:
+		return v
+	case int32:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case byte:
+		return int64(v)
+	}
+	return 0
+}
+
+func (m *Message) Kind() int32 {
+	return int32(m.Int64("__kind"))
+}
+
+func Encode(m *Message) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	version := m.ProtocolVersion
+	if version == 0 {
+		version = ProtocolVersion
+	}
+	buf.WriteByte(version)
+	buf.WriteByte(m.MessageType)
+
+	if m.StatusCode > 0 {
+		writeInt16(buf, headerStatusCode)
+		writeInt32(buf, m.StatusCode)
+	}
+	if m.StatusPhrase != "" {
+		writeInt16(buf, headerStatusPhrase)
+		writeCountedString(buf, m.StatusPhrase)
+	}
+	if m.Flag > 0 {
+		writeInt16(buf, headerFlag)
+		writeInt32(buf, m.Flag)
+	}
+	if m.Token != "" {
+		writeInt16(buf, headerToken)
+		writeCountedString(buf, m.Token)
+	}
+	for k, v := range m.Content {
+		writeInt16(buf, headerCustom)
+		writeCountedString(buf, k)
+		if err := writeCustomValue(buf, v); err != nil {
+			return nil, fmt.Errorf("tmc: encode header %s: %v", k, err)
+		}
+	}
+	writeInt16(buf, headerEndOfHeaders)
+	return buf.Bytes(), nil
+}
+
+func Decode(data []byte) (*Message, error) {
+	r := bytes.NewReader(data)
+	m := &Message{Content: map[string]interface{}{}}
+
+	var err error
+	if m.ProtocolVersion, err = r.ReadByte(); err != nil {
+		return nil, fmt.Errorf("tmc: decode version: %v", err)
+	}
+	if m.MessageType, err = r.ReadByte(); err != nil {
+		return nil, fmt.Errorf("tmc: decode message type: %v", err)
+	}
+
+	for {
+		var headerType int16
+		if err := binary.Read(r, binary.LittleEndian, &headerType); err != nil {
+			return nil, fmt.Errorf("tmc: decode header type: %v", err)
+		}
+		switch headerType {
+		case headerEndOfHeaders:
+			return m, nil
+		case headerCustom:
+			name, err := readCountedString(r)
+			if err != nil {
+				return nil, err
+			}
+			value, err := readCustomValue(r)
+			if err != nil {
+				return nil, fmt.Errorf("tmc: decode header %s: %v", name, err)
+			}
+			m.Content[name] = value
+		case headerStatusCode:
+			err = binary.Read(r, binary.LittleEndian, &m.StatusCode)
+		case headerStatusPhrase:
+			m.StatusPhrase, err = readCountedString(r)
+		case headerFlag:
+			err = binary.Read(r, binary.LittleEndian, &m.Flag)
+		case headerToken:
+			m.Token, err = readCountedString(r)
+		default:
+			return nil, fmt.Errorf("tmc: unknown header type %d", headerType)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("tmc: decode header %d: %v", headerType, err)
+		}
+	}
+}
+
+func writeInt16(buf *bytes.Buffer, v int16) {
+	_ = binary.Write(buf, binary.LittleEndian, v)
+}
+
+func writeInt32(buf *bytes.Buffer, v int32) {
+	_ = binary.Write(buf, binary.LittleEndian, v)
+}
+
+func writeCountedString(buf *bytes.Buffer, s string) {
+	writeInt32(buf, int32(len(s)))
+	buf.WriteString(s)
+}
+
+func writeCustomValue(buf *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteByte(valueVoid)
+	case string:
+		buf.WriteByte(valueString)
+		writeCountedString(buf, v)
+	case byte:
+		buf.WriteByte(valueByte)
+		buf.WriteByte(v)
+	case int16:
+		buf.WriteByte(valueInt16)
+		writeInt16(buf, v)
+	case int32:
+		buf.WriteByte(valueInt32)
+		writeInt32(buf, v)
+	case int:
+		buf.WriteByte(valueInt64)
+		_ = binary.Write(buf, binary.LittleEndian, int64(v))
+	case int64:
+		buf.WriteByte(valueInt64)
+		_ = binary.Write(buf, binary.LittleEndian, v)
+	case time.Time:
+		buf.WriteByte(valueDate)
+		_ = binary.Write(buf, binary.LittleEndian, v.UnixNano()/int64(time.Millisecond))
+	case []byte:
+		buf.WriteByte(valueByteArray)
+		writeInt32(buf, int32(len(v)))
+		buf.Write(v)
+	default:
+		return fmt.Errorf("unsupported value type %T", v)
+	}
+	return nil
+}
+
+func readCountedString(r *bytes.Reader) (string, error) {
+	var n int32
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return "", err
+	}
+	if n <= 0 {
+		return "", nil
+	}
+	if int(n) > r.Len() {
+		return "", io.ErrUnexpectedEOF
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func readCustomValue(r *bytes.Reader) (interface{}, error) {
+	format, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case valueVoid:
+		return nil, nil
+	case valueString:
+		return readCountedString(r)
+	case valueByte:
+		return r.ReadByte()
+	case valueInt16:
+		var v int16
+		err = binary.Read(r, binary.LittleEndian, &v)
+		return v, err
+	case valueInt32:
+		var v int32
+		err = binary.Read(r, binary.LittleEndian, &v)
+		return v, err
+	case valueInt64:
+		var v int64
+		err = binary.Read(r, binary.LittleEndian, &v)
+		return v, err
+	case valueDate:
+		var ms int64
+		if err = binary.Read(r, binary.LittleEndian, &ms); err != nil {
+			return nil, err
+		}
+		return time.Unix(0, ms*int64(time.Millisecond)), nil
+	case valueByteArray:
+		var n int32
+		if err = binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return nil, err
+		}
+		if n < 0 || int(n) > r.Len() {
+			return nil, io.ErrUnexpectedEOF
+		}
+		b := make([]byte, n)
+		_, err = io.ReadFull(r, b)
+		return b, err
+	}
+	return nil, fmt.Errorf("unknown value format %d", format)
+}
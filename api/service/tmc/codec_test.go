@@ -0,0 +1,93 @@
+package tmc
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	at := time.Date(2022, 5, 1, 12, 30, 0, 0, time.UTC)
+	in := &Message{
+		MessageType:  MessageTypeSend,
+		StatusCode:   401,
+		StatusPhrase: "invalid sign",
+		Flag:         1,
+		Token:        "token",
+		Content: map[string]interface{}{
+			"__kind":  KindData,
+			"id":      int64(1234567890123),
+			"topic":   "taobao_item_ItemUpdate",
+			"content": `{"num_iid":1}`,
+			"byte":    byte(7),
+			"int16":   int16(-2),
+			"void":    nil,
+			"time":    at,
+			"raw":     []byte{1, 2, 3},
+			"empty":   "",
+		},
+	}
+
+	data, err := Encode(in)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	out, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	if out.ProtocolVersion != ProtocolVersion || out.MessageType != MessageTypeSend {
+		t.Errorf("version/type = %d/%d", out.ProtocolVersion, out.MessageType)
+	}
+	if out.StatusCode != 401 || out.StatusPhrase != "invalid sign" || out.Flag != 1 || out.Token != "token" {
+		t.Errorf("headers = %+v", out)
+	}
+	if out.Kind() != KindData {
+		t.Errorf("Kind = %d", out.Kind())
+	}
+	if out.Int64("id") != 1234567890123 || out.Int64("byte") != 7 || out.Int64("int16") != -2 {
+		t.Errorf("ints = %v %v %v", out.Content["id"], out.Content["byte"], out.Content["int16"])
+	}
+	if out.String("topic") != "taobao_item_ItemUpdate" || out.String("content") != `{"num_iid":1}` || out.String("empty") != "" {
+		t.Errorf("strings = %v", out.Content)
+	}
+	if v, ok := out.Content["void"]; !ok || v != nil {
+		t.Errorf("void = %v, %v", v, ok)
+	}
+	if got, _ := out.Content["time"].(time.Time); !got.Equal(at) {
+		t.Errorf("time = %v, want %v", got, at)
+	}
+	if got, _ := out.Content["raw"].([]byte); !bytes.Equal(got, []byte{1, 2, 3}) {
+		t.Errorf("raw = %v", got)
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	valid, err := Encode(&Message{MessageType: MessageTypeSend, Token: "token", Content: map[string]interface{}{"topic": "x"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string][]byte{
+		"empty":          nil,
+		"no type":        {ProtocolVersion},
+		"no end":         valid[:len(valid)-2],
+		"truncated":      valid[:len(valid)-4],
+		"unknown header": {ProtocolVersion, MessageTypeSend, 9, 0},
+		// 字符串长度超出剩余数据
+		"long string":   {ProtocolVersion, MessageTypeSend, byte(headerToken), 0, 0xff, 0, 0, 0, 'a'},
+		"unknown value": {ProtocolVersion, MessageTypeSend, byte(headerCustom), 0, 1, 0, 0, 0, 'k', 99},
+	}
+	for name, data := range cases {
+		if _, err := Decode(data); err == nil {
+			t.Errorf("%s: want error", name)
+		}
+	}
+}
+
+func TestEncodeUnsupportedValue(t *testing.T) {
+	if _, err := Encode(&Message{Content: map[string]interface{}{"f": 1.5}}); err == nil {
+		t.Fatal("want error for float value")
+	}
+}
@@ -0,0 +1,274 @@
+package tmc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/grpool"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/logger"
+	"strconv"
+	"sync"
+	"tbTool/api/service/top"
+	"time"
+)
+
+const (
+	DefaultURL   = "ws://mc.api.taobao.com/"
+	DefaultGroup = "default"
+	SDK          = "tbtool-go"
+
+	defaultPullInterval = 5 * time.Second
+	handshakeTimeout    = 10 * time.Second
+	writeTimeout        = 10 * time.Second
+	minBackoff          = time.Second
+	maxBackoff          = time.Minute
+)
+
+var ErrConnectRejected = errors.New("tmc: connect rejected")
+
+// 推送给业务的消息
+type Event struct {
+	ID        int64     `json:"id"`
+	Topic     string    `json:"topic"`
+	Content   string    `json:"content"`
+	Time      time.Time `json:"time"`
+	UserID    int64     `json:"user_id"`
+	UserNick  string    `json:"user_nick"`
+	PubAppKey string    `json:"pub_app_key"`
+}
+
+// 返回 nil 时确认消息, 否则不确认, 由服务端重新投递
+type Handler func(ctx context.Context, e *Event) error
+
+type ConsumerConfig struct {
+	URL          string
+	AppKey       string
+	AppSecret    string
+	GroupName    string
+	PullInterval time.Duration
+}
+
+// 单个 app_key 的 TMC 长连接, 断线后按指数退避重连
+type Consumer struct {
+	cfg     ConsumerConfig
+	handler Handler
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewConsumer(cfg ConsumerConfig, handler Handler) *Consumer {
+	if cfg.URL == "" {
+		cfg.URL = DefaultURL
+	}
+	if cfg.GroupName == "" {
+		cfg.GroupName = DefaultGroup
+	}
+	if cfg.PullInterval <= 0 {
+		cfg.PullInterval = defaultPullInterval
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Consumer{
+		cfg:     cfg,
+		handler: handler,
+		ctx:     ctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+}
+
+// 阻塞运行直到 Close
+func (c *Consumer) Run() {
+	defer close(c.done)
+
+	backoff := minBackoff
+	for {
+		start := time.Now()
+		err := c.session()
+		if c.ctx.Err() != nil {
+			return
+		}
+		// 连接维持了一段时间再断开的视为正常断线, 重置退避
+		if time.Since(start) > maxBackoff {
+			backoff = minBackoff
+		}
+		logger.Warnf("tmc consumer %s disconnected: %v, reconnect in %s", c.cfg.AppKey, err, backoff)
+
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+func (c *Consumer) Close() {
+	c.cancel()
+	<-c.done
+}
+
+// 一次连接的完整生命周期: 握手, 定时拉取, 消费并确认
+func (c *Consumer) session() error {
+	dialer := websocket.Dialer{HandshakeTimeout: handshakeTimeout}
+	conn, _, err := dialer.DialContext(c.ctx, c.cfg.URL, nil)
+	if err != nil {
+		return fmt.Errorf("tmc: dial: %v", err)
+	}
+	defer conn.Close()
+
+	token, err := c.connect(conn)
+	if err != nil {
+		return err
+	}
+	logger.Infof("tmc consumer %s connected, group %s", c.cfg.AppKey, c.cfg.GroupName)
+
+	w := &writer{conn: conn, token: token}
+	stop := make(chan struct{})
+	defer close(stop)
+
+	if err := grpool.Submit(func() {
+		select {
+		case <-c.ctx.Done():
+			conn.Close()
+		case <-stop:
+		}
+	}); err != nil {
+		return err
+	}
+	if err := grpool.Submit(func() { c.pull(w, stop) }); err != nil {
+		return err
+	}
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return fmt.Errorf("tmc: read: %v", err)
+		}
+		msg, err := Decode(data)
+		if err != nil {
+			logger.Errorf("tmc consumer %s: %v", c.cfg.AppKey, err)
+			continue
+		}
+		if msg.MessageType != MessageTypeSend {
+			continue
+		}
+		if msg.Kind() != KindData && msg.String("topic") == "" {
+			continue
+		}
+		e := toEvent(msg)
+		if err := c.handler(c.ctx, e); err != nil {
+			logger.Errorf("tmc consumer %s handle %s(%d): %v", c.cfg.AppKey, e.Topic, e.ID, err)
+			continue
+		}
+		if err := w.send(map[string]interface{}{"__kind": KindConfirm, "id": e.ID}); err != nil {
+			return fmt.Errorf("tmc: confirm %d: %v", e.ID, err)
+		}
+	}
+}
+
+func (c *Consumer) connect(conn *websocket.Conn) (string, error) {
+	content := map[string]interface{}{
+		"app_key":    c.cfg.AppKey,
+		"group_name": c.cfg.GroupName,
+		"timestamp":  strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10),
+		"sdk":        SDK,
+	}
+	sign, err := ConnectSign(content, c.cfg.AppSecret)
+	if err != nil {
+		return "", err
+	}
+	content["sign"] = sign
+
+	data, err := Encode(&Message{MessageType: MessageTypeConnect, Content: content})
+	if err != nil {
+		return "", err
+	}
+	_ = conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if err := conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
+		return "", fmt.Errorf("tmc: send connect: %v", err)
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(handshakeTimeout))
+	_, data, err = conn.ReadMessage()
+	if err != nil {
+		return "", fmt.Errorf("tmc: read connect ack: %v", err)
+	}
+	_ = conn.SetReadDeadline(time.Time{})
+
+	ack, err := Decode(data)
+	if err != nil {
+		return "", err
+	}
+	if ack.MessageType != MessageTypeConnectAck {
+		return "", fmt.Errorf("tmc: unexpected message type %d during connect", ack.MessageType)
+	}
+	if ack.StatusCode > 0 {
+		return "", fmt.Errorf("%w: %d %s", ErrConnectRejected, ack.StatusCode, ack.StatusPhrase)
+	}
+	return ack.Token, nil
+}
+
+// 服务端只在收到拉取请求后推送积压消息
+func (c *Consumer) pull(w *writer, stop <-chan struct{}) {
+	ticker := time.NewTicker(c.cfg.PullInterval)
+	defer ticker.Stop()
+	for {
+		if err := w.send(map[string]interface{}{"__kind": KindPullRequest}); err != nil {
+			logger.Warnf("tmc consumer %s pull: %v", c.cfg.AppKey, err)
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// 连接签名与 TOP 的 md5 签名一致
+func ConnectSign(content map[string]interface{}, secret string) (string, error) {
+	params := map[string]string{}
+	for _, k := range []string{"app_key", "group_name", "timestamp"} {
+		if v, ok := content[k].(string); ok {
+			params[k] = v
+		}
+	}
+	return top.Sign(params, nil, secret, top.SignMethodMD5)
+}
+
+func toEvent(msg *Message) *Event {
+	e := &Event{
+		ID:        msg.Int64("id"),
+		Topic:     msg.String("topic"),
+		Content:   msg.String("content"),
+		UserID:    msg.Int64("user_id"),
+		UserNick:  msg.String("nick"),
+		PubAppKey: msg.String("publisher"),
+	}
+	if t, ok := msg.Content["time"].(time.Time); ok {
+		e.Time = t.In(top.Location)
+	}
+	return e
+}
+
+// websocket 连接不支持并发写
+type writer struct {
+	mu    sync.Mutex
+	conn  *websocket.Conn
+	token string
+}
+
+func (w *writer) send(content map[string]interface{}) error {
+	data, err := Encode(&Message{MessageType: MessageTypeSend, Token: w.token, Content: content})
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_ = w.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return w.conn.WriteMessage(websocket.BinaryMessage, data)
+}
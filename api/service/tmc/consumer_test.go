@@ -0,0 +1,141 @@
+package tmc_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"tbTool/api/service/tmc"
+	"tbTool/api/service/tmc/tmctest"
+	"testing"
+	"time"
+)
+
+const (
+	appKey    = "tmc-app"
+	appSecret = "tmc-secret"
+)
+
+type recorder struct {
+	mu     sync.Mutex
+	events []*tmc.Event
+	// 前 fails 次调用返回错误
+	fails int
+}
+
+func (r *recorder) handle(_ context.Context, e *tmc.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+	if r.fails > 0 {
+		r.fails--
+		return errors.New("handler failed")
+	}
+	return nil
+}
+
+func (r *recorder) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.events)
+}
+
+func startConsumer(t *testing.T, srv *tmctest.Server, secret string, h tmc.Handler) *tmc.Consumer {
+	c := tmc.NewConsumer(tmc.ConsumerConfig{
+		URL:          srv.URL(),
+		AppKey:       appKey,
+		AppSecret:    secret,
+		PullInterval: 50 * time.Millisecond,
+	}, h)
+	go c.Run()
+	t.Cleanup(c.Close)
+	return c
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestConsumerHandshakeDeliverConfirm(t *testing.T) {
+	srv := tmctest.NewServer(appKey, appSecret)
+	defer srv.Close()
+	r := &recorder{}
+	startConsumer(t, srv, appSecret, r.handle)
+
+	waitFor(t, "connect", func() bool { return srv.Connects() == 1 })
+	id := srv.Push("taobao_item_ItemUpdate", `{"num_iid":1}`, 42)
+	if err := srv.WaitConfirmed(id, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.events) != 1 {
+		t.Fatalf("events = %d, want 1", len(r.events))
+	}
+	e := r.events[0]
+	if e.ID != id || e.Topic != "taobao_item_ItemUpdate" || e.Content != `{"num_iid":1}` || e.UserID != 42 {
+		t.Errorf("event = %+v", e)
+	}
+	if e.Time.IsZero() {
+		t.Error("event time not decoded")
+	}
+}
+
+func TestConsumerRejectedSign(t *testing.T) {
+	srv := tmctest.NewServer(appKey, appSecret)
+	defer srv.Close()
+	r := &recorder{}
+	startConsumer(t, srv, "wrong-secret", r.handle)
+
+	srv.Push("taobao_item_ItemUpdate", "{}", 1)
+	time.Sleep(300 * time.Millisecond)
+	if srv.Connects() != 0 || r.count() != 0 {
+		t.Fatalf("connects = %d, events = %d, want none", srv.Connects(), r.count())
+	}
+}
+
+func TestConsumerReconnectRedelivers(t *testing.T) {
+	srv := tmctest.NewServer(appKey, appSecret)
+	defer srv.Close()
+	r := &recorder{}
+	startConsumer(t, srv, appSecret, r.handle)
+	waitFor(t, "connect", func() bool { return srv.Connects() == 1 })
+
+	srv.DropConnections()
+	id := srv.Push("taobao_trade_TradeCreate", "{}", 1)
+	waitFor(t, "reconnect", func() bool { return srv.Connects() == 2 })
+	if err := srv.WaitConfirmed(id, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 处理失败的消息不确认, 断线重连后重新投递
+func TestConsumerHandlerErrorNotConfirmed(t *testing.T) {
+	srv := tmctest.NewServer(appKey, appSecret)
+	defer srv.Close()
+	r := &recorder{fails: 1}
+	startConsumer(t, srv, appSecret, r.handle)
+	waitFor(t, "connect", func() bool { return srv.Connects() == 1 })
+
+	id := srv.Push("taobao_item_ItemUpdate", "{}", 1)
+	waitFor(t, "first delivery", func() bool { return r.count() == 1 })
+	time.Sleep(100 * time.Millisecond)
+	if srv.Confirmed(id) {
+		t.Fatal("failed message was confirmed")
+	}
+
+	srv.DropConnections()
+	if err := srv.WaitConfirmed(id, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if r.count() != 2 {
+		t.Fatalf("deliveries = %d, want 2", r.count())
+	}
+}
@@ -0,0 +1,53 @@
+package tmc
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"tbTool/api/service/items"
+	"tbTool/api/service/shop"
+)
+
+const itemTopicPrefix = "taobao_item_"
+
+// 商品变更消息失效对应店铺的出售中商品缓存
+// 按 app_key + 卖家匹配店铺, 匹配不到卖家时失效该应用下的全部店铺
+func InvalidateItems(cache *items.OnSaleCache, appKey string) Handler {
+	return func(ctx context.Context, e *Event) error {
+		if !strings.HasPrefix(e.Topic, itemTopicPrefix) {
+			return nil
+		}
+
+		userID := strconv.FormatInt(e.UserID, 10)
+		var all, matched []string
+		shop.Range(func(s *shop.Shop) bool {
+			if s.AppKey != appKey {
+				return true
+			}
+			all = append(all, s.ID)
+			if s.SellerID != "" && (s.SellerID == userID || s.SellerID == e.UserNick) {
+				matched = append(matched, s.ID)
+			}
+			return true
+		})
+		if len(matched) == 0 {
+			matched = all
+		}
+		for _, id := range matched {
+			cache.Invalidate(ctx, id)
+		}
+		return nil
+	}
+}
+
+// 依次执行, 任一失败则不确认消息
+func Chain(handlers ...Handler) Handler {
+	return func(ctx context.Context, e *Event) error {
+		for _, h := range handlers {
+			if err := h(ctx, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,145 @@
+package tmc
+
+import (
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/conf"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/grpool"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/logger"
+	"sync"
+	"tbTool/api/service/items"
+	"tbTool/api/service/shop"
+)
+
+// 为每个 app_key 维持一条 TMC 连接, 消息转发到 rabbit
+// 店铺增删或凭证变更后按当前店铺重新对齐连接
+type Job struct {
+	p     Publisher
+	cache *items.OnSaleCache
+
+	mu        sync.Mutex
+	running   bool
+	cfg       ConsumerConfig
+	handler   func(appKey string) Handler
+	consumers map[string]*appConsumer
+	unwatch   func()
+}
+
+type appConsumer struct {
+	secret string
+	c      *Consumer
+}
+
+func NewJob(p Publisher, cache *items.OnSaleCache) *Job {
+	return &Job{p: p, cache: cache}
+}
+
+// tmc.enabled 未开启时不建立连接
+func (j *Job) Start() error {
+	if !conf.GetBoolFormConfigFile("tmc.enabled") {
+		return nil
+	}
+	prefixes := conf.GetStringSliceFormConfigFile("tmc.topic_prefixes")
+	if len(prefixes) == 0 {
+		prefixes = DefaultTopicPrefixes
+	}
+	republish := Republish(j.p, prefixes)
+
+	return j.start(ConsumerConfig{
+		URL:          conf.GetStringFormConfigFile("tmc.url"),
+		GroupName:    conf.GetStringFormConfigFile("tmc.group_name"),
+		PullInterval: conf.GetDurationFormConfigFile("tmc.pull_interval"),
+	}, func(appKey string) Handler {
+		return Chain(InvalidateItems(j.cache, appKey), republish)
+	})
+}
+
+// cfg 中的 AppKey/AppSecret 由店铺配置填充
+func (j *Job) start(cfg ConsumerConfig, handler func(appKey string) Handler) error {
+	j.mu.Lock()
+	j.running = true
+	j.cfg = cfg
+	j.handler = handler
+	j.consumers = map[string]*appConsumer{}
+	j.mu.Unlock()
+
+	// 先订阅再对齐, 避免漏掉两者之间的变更
+	unwatch := shop.Watch(func() {
+		if err := j.reconcile(); err != nil {
+			logger.Errorf("tmc job reconcile error: %v", err)
+		}
+	})
+	j.mu.Lock()
+	j.unwatch = unwatch
+	j.mu.Unlock()
+
+	return j.reconcile()
+}
+
+// 新出现的 app_key 建立连接, 不再使用的断开, secret 变更的重连
+func (j *Job) reconcile() error {
+	// 同一应用的多个店铺共用一条连接
+	apps := map[string]string{}
+	shop.Range(func(s *shop.Shop) bool {
+		if s.AppKey != "" {
+			apps[s.AppKey] = s.AppSecret
+		}
+		return true
+	})
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if !j.running {
+		return nil
+	}
+
+	for appKey, ac := range j.consumers {
+		if secret, ok := apps[appKey]; ok && secret == ac.secret {
+			continue
+		}
+		ac.c.Close()
+		delete(j.consumers, appKey)
+		logger.Infof("tmc consumer %s stopped", appKey)
+	}
+	for appKey, secret := range apps {
+		if _, ok := j.consumers[appKey]; ok {
+			continue
+		}
+		cfg := j.cfg
+		cfg.AppKey = appKey
+		cfg.AppSecret = secret
+		c := NewConsumer(cfg, j.handler(appKey))
+		if err := grpool.Submit(c.Run); err != nil {
+			return err
+		}
+		j.consumers[appKey] = &appConsumer{secret: secret, c: c}
+	}
+	return nil
+}
+
+// 当前维持连接的 app_key
+func (j *Job) AppKeys() []string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	keys := make([]string, 0, len(j.consumers))
+	for appKey := range j.consumers {
+		keys = append(keys, appKey)
+	}
+	return keys
+}
+
+func (j *Job) Stop() error {
+	j.mu.Lock()
+	unwatch := j.unwatch
+	j.unwatch = nil
+	j.running = false
+	consumers := j.consumers
+	j.consumers = nil
+	j.mu.Unlock()
+
+	if unwatch != nil {
+		unwatch()
+	}
+	for _, ac := range consumers {
+		ac.c.Close()
+	}
+	return nil
+}
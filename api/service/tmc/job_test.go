@@ -0,0 +1,74 @@
+package tmc
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"tbTool/api/service/shop"
+	"testing"
+)
+
+func storeShop(t *testing.T, id, appKey, secret string) {
+	t.Helper()
+	if err := shop.Store(&shop.Shop{ID: id, AppKey: appKey, AppSecret: secret, Gateway: "http://127.0.0.1:1/router/rest"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func appKeys(j *Job) []string {
+	keys := j.AppKeys()
+	sort.Strings(keys)
+	return keys
+}
+
+// 店铺增删后连接随之增删, 同一应用的店铺共用连接
+func TestJobFollowsShopChanges(t *testing.T) {
+	storeShop(t, "tmc-job-a", "job-app-1", "s1")
+	defer shop.Delete("tmc-job-a")
+
+	j := &Job{}
+	noop := func(string) Handler { return func(context.Context, *Event) error { return nil } }
+	// 连接不上的地址, 消费者只会退避重试
+	if err := j.start(ConsumerConfig{URL: "ws://127.0.0.1:1/"}, noop); err != nil {
+		t.Fatal(err)
+	}
+	defer j.Stop()
+
+	if got := appKeys(j); !reflect.DeepEqual(got, []string{"job-app-1"}) {
+		t.Fatalf("app keys = %v", got)
+	}
+
+	storeShop(t, "tmc-job-b", "job-app-2", "s2")
+	storeShop(t, "tmc-job-c", "job-app-1", "s1")
+	if got := appKeys(j); !reflect.DeepEqual(got, []string{"job-app-1", "job-app-2"}) {
+		t.Fatalf("app keys after add = %v", got)
+	}
+
+	j.mu.Lock()
+	before := j.consumers["job-app-2"].c
+	j.mu.Unlock()
+	storeShop(t, "tmc-job-b", "job-app-2", "s2-rotated")
+	j.mu.Lock()
+	after := j.consumers["job-app-2"].c
+	j.mu.Unlock()
+	if before == after {
+		t.Fatal("consumer not restarted after secret change")
+	}
+
+	shop.Delete("tmc-job-b")
+	if got := appKeys(j); !reflect.DeepEqual(got, []string{"job-app-1"}) {
+		t.Fatalf("app keys after delete = %v", got)
+	}
+	// 同应用还有其他店铺时保留连接
+	shop.Delete("tmc-job-c")
+	if got := appKeys(j); !reflect.DeepEqual(got, []string{"job-app-1"}) {
+		t.Fatalf("app keys after deleting shared shop = %v", got)
+	}
+
+	j.Stop()
+	storeShop(t, "tmc-job-d", "job-app-3", "s3")
+	defer shop.Delete("tmc-job-d")
+	if got := j.AppKeys(); len(got) != 0 {
+		t.Fatalf("app keys after stop = %v", got)
+	}
+}
@@ -0,0 +1,61 @@
+package tmc
+
+import (
+	"context"
+	"encoding/json"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/rabbitmq"
+	"strings"
+	"tbTool/api/tools/common"
+)
+
+const TopicPrefix = "tbtool.tmc."
+
+// 只转发交易, 商品, 退款相关的消息
+var DefaultTopicPrefixes = []string{"taobao_trade_", "taobao_item_", "taobao_refund_"}
+
+type Publisher interface {
+	Publish(ctx context.Context, topic string, msg []byte) error
+}
+
+type RabbitPublisher struct{}
+
+func NewRabbitPublisher() Publisher {
+	return &RabbitPublisher{}
+}
+
+func (p *RabbitPublisher) Publish(ctx context.Context, topic string, msg []byte) error {
+	ch, err := rabbitmq.PickupRabbitClient(ctx, common.RabbitName)
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+	return ch.DioPublish(topic, msg)
+}
+
+// 每种消息类型对应一个 rabbit topic, 如 tbtool.tmc.taobao_trade_TradeChanged
+func RabbitTopic(tmcTopic string) string {
+	return TopicPrefix + tmcTopic
+}
+
+func Accept(tmcTopic string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(tmcTopic, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// 转发到 rabbit, 成功后才确认; 不关心的消息直接确认丢弃
+func Republish(p Publisher, prefixes []string) Handler {
+	return func(ctx context.Context, e *Event) error {
+		if !Accept(e.Topic, prefixes) {
+			return nil
+		}
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		return p.Publish(ctx, RabbitTopic(e.Topic), data)
+	}
+}
@@ -0,0 +1,218 @@
+// 本地 TMC 替身服务, 用于联调和测试消费者的握手, 推送, 确认与重连
+package tmctest
+
+import (
+	"errors"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"tbTool/api/service/tmc"
+	"time"
+)
+
+const token = "tmctest-token"
+
+type pending struct {
+	msg       *tmc.Message
+	delivered bool
+}
+
+type Server struct {
+	appKey    string
+	appSecret string
+	srv       *httptest.Server
+	upgrader  websocket.Upgrader
+
+	mu        sync.Mutex
+	nextID    int64
+	conns     map[*websocket.Conn]struct{}
+	queue     []*pending
+	confirmed map[int64]bool
+	connects  int
+	notify    chan struct{}
+}
+
+// 只接受 appKey/appSecret 签名的连接
+func NewServer(appKey, appSecret string) *Server {
+	s := &Server{
+		appKey:    appKey,
+		appSecret: appSecret,
+		conns:     map[*websocket.Conn]struct{}{},
+		confirmed: map[int64]bool{},
+		notify:    make(chan struct{}, 1),
+	}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+func (s *Server) URL() string {
+	return "ws" + strings.TrimPrefix(s.srv.URL, "http")
+}
+
+func (s *Server) Close() {
+	s.DropConnections()
+	s.srv.Close()
+}
+
+// 放入一条待投递消息, 收到拉取请求或已有连接时推送, 返回消息 id
+func (s *Server) Push(topic, content string, userID int64) int64 {
+	s.mu.Lock()
+	s.nextID++
+	id := s.nextID
+	s.queue = append(s.queue, &pending{msg: &tmc.Message{
+		MessageType: tmc.MessageTypeSend,
+		Content: map[string]interface{}{
+			"__kind":  tmc.KindData,
+			"id":      id,
+			"topic":   topic,
+			"content": content,
+			"time":    time.Now(),
+			"user_id": userID,
+		},
+	}})
+	s.mu.Unlock()
+	s.deliver()
+	return id
+}
+
+// 断开所有连接, 未确认的消息在重连后重新投递
+func (s *Server) DropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = map[*websocket.Conn]struct{}{}
+	for _, p := range s.queue {
+		p.delivered = false
+	}
+}
+
+func (s *Server) Confirmed(id int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.confirmed[id]
+}
+
+// 握手成功的次数, 用于观察重连
+func (s *Server) Connects() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connects
+}
+
+func (s *Server) WaitConfirmed(id int64, timeout time.Duration) error {
+	deadline := time.After(timeout)
+	for !s.Confirmed(id) {
+		select {
+		case <-deadline:
+			return errors.New("tmctest: wait confirm timeout")
+		case <-s.notify:
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	return nil
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	if !s.handshake(conn) {
+		return
+	}
+
+	s.mu.Lock()
+	s.conns[conn] = struct{}{}
+	s.connects++
+	s.mu.Unlock()
+	s.deliver()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		msg, err := tmc.Decode(data)
+		if err != nil || msg.MessageType != tmc.MessageTypeSend || msg.Token != token {
+			continue
+		}
+		switch msg.Kind() {
+		case tmc.KindPullRequest:
+			s.deliver()
+		case tmc.KindConfirm:
+			s.confirm(msg.Int64("id"))
+		}
+	}
+
+	s.mu.Lock()
+	delete(s.conns, conn)
+	s.mu.Unlock()
+}
+
+func (s *Server) handshake(conn *websocket.Conn) bool {
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		return false
+	}
+	msg, err := tmc.Decode(data)
+	if err != nil || msg.MessageType != tmc.MessageTypeConnect {
+		return false
+	}
+
+	ack := &tmc.Message{MessageType: tmc.MessageTypeConnectAck, Token: token}
+	sign, _ := tmc.ConnectSign(msg.Content, s.appSecret)
+	if msg.String("app_key") != s.appKey || msg.String("sign") != sign {
+		ack = &tmc.Message{MessageType: tmc.MessageTypeConnectAck, StatusCode: 401, StatusPhrase: "invalid sign"}
+	}
+	out, _ := tmc.Encode(ack)
+	if err := conn.WriteMessage(websocket.BinaryMessage, out); err != nil {
+		return false
+	}
+	return ack.StatusCode == 0
+}
+
+func (s *Server) confirm(id int64) {
+	s.mu.Lock()
+	s.confirmed[id] = true
+	queue := s.queue[:0]
+	for _, p := range s.queue {
+		if p.msg.Int64("id") != id {
+			queue = append(queue, p)
+		}
+	}
+	s.queue = queue
+	s.mu.Unlock()
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+// 未确认的消息只投递一次, 断线后重新投递
+func (s *Server) deliver() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// 同组多个连接时只投递给其中一个
+	for conn := range s.conns {
+		for _, p := range s.queue {
+			if p.delivered {
+				continue
+			}
+			data, err := tmc.Encode(p.msg)
+			if err != nil {
+				continue
+			}
+			if conn.WriteMessage(websocket.BinaryMessage, data) == nil {
+				p.delivered = true
+			}
+		}
+		break
+	}
+}
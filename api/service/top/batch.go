@@ -0,0 +1,182 @@
+package top
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"tbTool/pkg/request"
+	"time"
+)
+
+const (
+	// 单个批量请求最多包含的子调用数
+	MaxBatchSize = 50
+
+	// 批量请求体/响应体中各子调用之间的分隔符
+	BatchSeparator = "\r\n-S-\r\n"
+)
+
+var (
+	ErrBatchMismatch = errors.New("top: batch response count mismatch")
+	// 未配置批量网关, 调用方应逐个调用
+	ErrBatchDisabled = errors.New("top: batch gateway not configured")
+)
+
+// 批量调用中的一个子调用, Out 为 nil 时不解码响应
+type BatchCall struct {
+	Method string
+	Params Params
+	Out    interface{}
+}
+
+// 支持批量调用的 Caller
+type BatchCaller interface {
+	Caller
+	// 返回的错误与 calls 一一对应, 单个子调用失败不影响其它子调用
+	Batch(ctx context.Context, calls []BatchCall) []error
+}
+
+// 通过批量网关调用, 超过 MaxBatchSize 个时分批发送
+// 未配置 batch_gateway 时所有子调用返回 ErrBatchDisabled, 不发出任何请求
+func (cli *Client) Batch(ctx context.Context, calls []BatchCall) []error {
+	errs := make([]error, len(calls))
+	if cli.cfg.BatchGateway == "" {
+		for i := range errs {
+			errs[i] = ErrBatchDisabled
+		}
+		return errs
+	}
+	for start := 0; start < len(calls); start += MaxBatchSize {
+		end := start + MaxBatchSize
+		if end > len(calls) {
+			end = len(calls)
+		}
+		if err := ctx.Err(); err != nil {
+			for i := start; i < len(calls); i++ {
+				errs[i] = err
+			}
+			break
+		}
+		cli.batch(ctx, calls[start:end], errs[start:end])
+	}
+	return errs
+}
+
+// 发送一批子调用, 结果写入 errs 的对应下标
+func (cli *Client) batch(ctx context.Context, calls []BatchCall, errs []error) {
+	// 配额按子调用计算, 额度不足的子调用不进入本批
+	idx := make([]int, 0, len(calls))
+	for i, call := range calls {
+		if l := currentLimiter(); l != nil {
+			if err := l.Wait(ctx, cli.cfg.AppKey, call.Method, call.Params["session"]); err != nil {
+				errs[i] = err
+				continue
+			}
+		}
+		idx = append(idx, i)
+	}
+	if len(idx) == 0 {
+		return
+	}
+	fail := func(err error) {
+		for _, i := range idx {
+			errs[i] = err
+		}
+	}
+
+	body := encodeBatch(calls, idx)
+	values, err := cli.signedBatchValues(body)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	_, data, err := request.Post(joinQuery(cli.cfg.BatchGateway, values), body, cli.cfg.Timeout, cli.batchAttempts(calls, idx),
+		request.WithContext(ctx), request.Headers(map[string]string{"Content-Type": "text/plain;charset=utf-8"}))
+	if err != nil {
+		fail(callError("batch call", err))
+		return
+	}
+
+	parts := strings.Split(string(data), BatchSeparator)
+	if len(parts) != len(idx) {
+		// 签名错误等整体失败时只返回一个 error_response
+		if te, ok := batchError([]byte(parts[0])); ok {
+			fail(te)
+			return
+		}
+		fail(fmt.Errorf("%w: sent %d, got %d", ErrBatchMismatch, len(idx), len(parts)))
+		return
+	}
+	for n, i := range idx {
+		errs[i] = decodeResponse(calls[i].Method, []byte(parts[n]), calls[i].Out)
+	}
+}
+
+func batchError(data []byte) (*TopError, bool) {
+	var envelope struct {
+		ErrorResponse *TopError `json:"error_response"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil || envelope.ErrorResponse == nil {
+		return nil, false
+	}
+	return envelope.ErrorResponse, true
+}
+
+// 每行一个子调用: method=xxx&k=v..., 行间以 BatchSeparator 分隔
+func encodeBatch(calls []BatchCall, idx []int) []byte {
+	var buf bytes.Buffer
+	for n, i := range idx {
+		if n > 0 {
+			buf.WriteString(BatchSeparator)
+		}
+		values := url.Values{}
+		for k, v := range calls[i].Params {
+			if v == "" {
+				continue
+			}
+			values.Set(k, v)
+		}
+		values.Set("method", calls[i].Method)
+		buf.WriteString(values.Encode())
+	}
+	return buf.Bytes()
+}
+
+// 批量请求只在 query 中携带系统参数, 请求体参与签名
+func (cli *Client) signedBatchValues(body []byte) (url.Values, error) {
+	all := map[string]string{
+		"app_key":     cli.cfg.AppKey,
+		"timestamp":   time.Now().In(Location).Format(TimestampLayout),
+		"format":      Format,
+		"v":           Version,
+		"sign_method": cli.cfg.SignMethod,
+		"partner_id":  PartnerID,
+	}
+
+	sign, err := Sign(all, body, cli.cfg.AppSecret, cli.cfg.SignMethod)
+	if err != nil {
+		return nil, err
+	}
+	all["sign"] = sign
+
+	values := url.Values{}
+	for k, v := range all {
+		values.Set(k, v)
+	}
+	return values, nil
+}
+
+// 同 attempts, 批内全部是查询接口时才重试
+func (cli *Client) batchAttempts(calls []BatchCall, idx []int) int {
+	for _, i := range idx {
+		if !IsReadMethod(calls[i].Method) {
+			return 1
+		}
+	}
+	return cli.cfg.Retries
+}
@@ -0,0 +1,109 @@
+package top
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// 第一次请求直接断开连接, 之后按子调用个数返回成功响应
+type flakyGateway struct {
+	mu     sync.Mutex
+	hits   int
+	bodies []string
+}
+
+func (g *flakyGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	g.mu.Lock()
+	g.hits++
+	hits := g.hits
+	g.bodies = append(g.bodies, string(body))
+	g.mu.Unlock()
+
+	if hits == 1 {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+		return
+	}
+	var parts []string
+	for _, line := range strings.Split(string(body), BatchSeparator) {
+		method := strings.TrimPrefix(line[strings.Index(line, "method="):], "method=")
+		if i := strings.Index(method, "&"); i >= 0 {
+			method = method[:i]
+		}
+		parts = append(parts, `{"`+ResponseKey(method)+`":{}}`)
+	}
+	w.Write([]byte(strings.Join(parts, BatchSeparator)))
+}
+
+func newBatchClient(batchGateway string) *Client {
+	return NewClient(Config{
+		AppKey:       "12345678",
+		AppSecret:    "helloworld",
+		Gateway:      "http://127.0.0.1:0/router/rest",
+		BatchGateway: batchGateway,
+		Retries:      3,
+	})
+}
+
+func TestBatchDisabled(t *testing.T) {
+	errs := newBatchClient("").Batch(context.Background(), []BatchCall{
+		{Method: "taobao.time.get", Params: Params{}},
+		{Method: "taobao.item.quantity.update", Params: Params{}},
+	})
+	for i, err := range errs {
+		if !errors.Is(err, ErrBatchDisabled) {
+			t.Errorf("errs[%d] = %v, want ErrBatchDisabled", i, err)
+		}
+	}
+}
+
+func TestBatchRetriesReadsWithBody(t *testing.T) {
+	g := &flakyGateway{}
+	srv := httptest.NewServer(g)
+	defer srv.Close()
+
+	errs := newBatchClient(srv.URL+"/router/batch").Batch(context.Background(), []BatchCall{
+		{Method: "taobao.time.get", Params: Params{}},
+		{Method: "taobao.item.seller.get", Params: Params{"num_iid": "1"}},
+	})
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("errs[%d] = %v", i, err)
+		}
+	}
+	if g.hits != 2 {
+		t.Fatalf("hits = %d, want 2", g.hits)
+	}
+	// 重试时请求体完整重发
+	if g.bodies[1] == "" || g.bodies[1] != g.bodies[0] {
+		t.Errorf("retry body = %q, want %q", g.bodies[1], g.bodies[0])
+	}
+}
+
+func TestBatchDoesNotRetryWrites(t *testing.T) {
+	g := &flakyGateway{}
+	srv := httptest.NewServer(g)
+	defer srv.Close()
+
+	errs := newBatchClient(srv.URL+"/router/batch").Batch(context.Background(), []BatchCall{
+		{Method: "taobao.time.get", Params: Params{}},
+		{Method: "taobao.item.quantity.update", Params: Params{"num_iid": "1", "quantity": "2"}},
+	})
+	for i, err := range errs {
+		if err == nil {
+			t.Errorf("errs[%d] = nil, want transport error", i)
+		}
+	}
+	if g.hits != 1 {
+		t.Fatalf("hits = %d, want 1", g.hits)
+	}
+}
@@ -0,0 +1,255 @@
+package top
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"tbTool/api/tools/toptime"
+	"tbTool/pkg/request"
+	"time"
+)
+
+const (
+	Version         = "2.0"
+	Format          = "json"
+	PartnerID       = "top-apitools"
+	TimestampLayout = toptime.Layout
+
+	defaultTimeout = 5 * time.Second
+	defaultRetries = 3
+)
+
+// TOP 接口时间均为东八区
+var Location = toptime.Location
+
+func ParseTime(s string) (time.Time, error) {
+	return toptime.Parse(s)
+}
+
+type Config struct {
+	AppKey    string
+	AppSecret string
+	Gateway   string
+	// 批量网关, 为空时不使用批量调用
+	BatchGateway string
+	SignMethod   string
+	// http.MethodGet 或 http.MethodPost(表单), 默认 POST
+	HTTPMethod string
+	Timeout    time.Duration
+	Retries    int
+}
+
+// 业务参数, session 也作为普通参数传入
+type Params map[string]string
+
+func (p Params) Set(key string, value interface{}) Params {
+	switch v := value.(type) {
+	case string:
+		p[key] = v
+	case int:
+		p[key] = strconv.Itoa(v)
+	case int64:
+		p[key] = strconv.FormatInt(v, 10)
+	case bool:
+		p[key] = strconv.FormatBool(v)
+	case time.Time:
+		p[key] = v.In(Location).Format(TimestampLayout)
+	case []string:
+		p[key] = strings.Join(v, ",")
+	default:
+		p[key] = fmt.Sprint(v)
+	}
+	return p
+}
+
+type Caller interface {
+	Call(ctx context.Context, method string, params Params, out interface{}) error
+}
+
+// 支持文件上传的 Caller
+type UploadCaller interface {
+	Caller
+	Upload(ctx context.Context, method string, params Params, files []request.File, out interface{}) error
+}
+
+type Client struct {
+	cfg Config
+}
+
+func NewClient(cfg Config) *Client {
+	if cfg.SignMethod == "" {
+		cfg.SignMethod = SignMethodMD5
+	}
+	if cfg.HTTPMethod == "" {
+		cfg.HTTPMethod = http.MethodPost
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.Retries <= 0 {
+		cfg.Retries = defaultRetries
+	}
+	return &Client{cfg: cfg}
+}
+
+func (cli *Client) Config() Config {
+	return cli.cfg
+}
+
+// 超时后服务端可能已执行, 只有查询接口(*.get)按配置重试, 写接口只发一次避免重复扣减库存等
+func (cli *Client) attempts(method string) int {
+	if IsReadMethod(method) {
+		return cli.cfg.Retries
+	}
+	return 1
+}
+
+// 查询类接口, 重复调用无副作用
+func IsReadMethod(method string) bool {
+	return strings.HasSuffix(method, ".get")
+}
+
+// 调用TOP接口, 将 <method>_response 解码到 out, error_response 转为 *TopError
+func (cli *Client) Call(ctx context.Context, method string, params Params, out interface{}) error {
+	if l := currentLimiter(); l != nil {
+		if err := l.Wait(ctx, cli.cfg.AppKey, method, params["session"]); err != nil {
+			return err
+		}
+	}
+
+	values, err := cli.signedValues(method, params)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	if cli.cfg.HTTPMethod == http.MethodGet {
+		_, data, err = request.Get(joinQuery(cli.cfg.Gateway, values), cli.cfg.Timeout, cli.attempts(method), request.WithContext(ctx))
+	} else {
+		_, data, err = request.PostForm(cli.cfg.Gateway, values, cli.cfg.Timeout, cli.attempts(method), request.WithContext(ctx))
+	}
+	if err != nil {
+		return callError("call "+method, err)
+	}
+
+	return decodeResponse(method, data, out)
+}
+
+// 带文件参数的调用, 以 multipart/form-data 提交; 文件参数不参与签名
+// 上传均为写操作, 超时不重试
+func (cli *Client) Upload(ctx context.Context, method string, params Params, files []request.File, out interface{}) error {
+	if l := currentLimiter(); l != nil {
+		if err := l.Wait(ctx, cli.cfg.AppKey, method, params["session"]); err != nil {
+			return err
+		}
+	}
+
+	values, err := cli.signedValues(method, params)
+	if err != nil {
+		return err
+	}
+
+	_, data, err := request.PostMultipart(cli.cfg.Gateway, values, files, cli.cfg.Timeout, 1, request.WithContext(ctx))
+	if err != nil {
+		return callError("call "+method, err)
+	}
+
+	return decodeResponse(method, data, out)
+}
+
+// 合并系统参数与业务参数并签名
+func (cli *Client) signedValues(method string, params Params) (url.Values, error) {
+	all := map[string]string{
+		"method":      method,
+		"app_key":     cli.cfg.AppKey,
+		"timestamp":   time.Now().In(Location).Format(TimestampLayout),
+		"format":      Format,
+		"v":           Version,
+		"sign_method": cli.cfg.SignMethod,
+		"partner_id":  PartnerID,
+	}
+	for k, v := range params {
+		all[k] = v
+	}
+
+	sign, err := Sign(all, nil, cli.cfg.AppSecret, cli.cfg.SignMethod)
+	if err != nil {
+		return nil, err
+	}
+	all["sign"] = sign
+
+	values := url.Values{}
+	for k, v := range all {
+		if v == "" {
+			continue
+		}
+		values.Set(k, v)
+	}
+	return values, nil
+}
+
+// 传输错误中的 url 带有 sign, GET 模式下还有 session, 只保留操作和底层错误, 避免写入日志或返回给调用方
+func callError(op string, err error) error {
+	var uerr *url.Error
+	if errors.As(err, &uerr) {
+		err = uerr.Err
+	}
+	return fmt.Errorf("top: %s: %v", op, err)
+}
+
+func joinQuery(gateway string, values url.Values) string {
+	if strings.Contains(gateway, "?") {
+		return gateway + "&" + values.Encode()
+	}
+	return gateway + "?" + values.Encode()
+}
+
+// taobao.items.onsale.get -> items_onsale_get_response
+func ResponseKey(method string) string {
+	return strings.ReplaceAll(strings.TrimPrefix(method, "taobao."), ".", "_") + "_response"
+}
+
+func decodeResponse(method string, data []byte, out interface{}) error {
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return fmt.Errorf("top: decode %s response: %v", method, err)
+	}
+
+	if raw, ok := envelope["error_response"]; ok {
+		te := &TopError{}
+		if err := json.Unmarshal(raw, te); err != nil {
+			return fmt.Errorf("top: decode %s error_response: %v", method, err)
+		}
+		return te
+	}
+
+	raw, ok := envelope[ResponseKey(method)]
+	if !ok {
+		return fmt.Errorf("top: %s response missing %s", method, ResponseKey(method))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("top: decode %s: %v", method, err)
+	}
+	return nil
+}
+
+// 日志输出时隐藏 secret
+func (cfg Config) String() string {
+	return fmt.Sprintf("{app_key:%s app_secret:%s gateway:%s batch_gateway:%s sign_method:%s http_method:%s timeout:%s retries:%d}",
+		cfg.AppKey, Mask(cfg.AppSecret), cfg.Gateway, cfg.BatchGateway, cfg.SignMethod, cfg.HTTPMethod, cfg.Timeout, cfg.Retries)
+}
+
+func Mask(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "******"
+}
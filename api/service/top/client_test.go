@@ -0,0 +1,36 @@
+package top
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// GET 模式下 session 和 sign 在 url 中, 传输错误不能带出 url
+func TestCallErrorHidesURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if conn, _, err := w.(http.Hijacker).Hijack(); err == nil {
+			conn.Close()
+		}
+	}))
+	defer srv.Close()
+
+	cli := NewClient(Config{
+		AppKey:     "12345678",
+		AppSecret:  "helloworld",
+		Gateway:    srv.URL + "/router/rest",
+		HTTPMethod: http.MethodGet,
+		Retries:    1,
+	})
+	err := cli.Call(context.Background(), "taobao.item.seller.get", Params{"session": "secret-session", "num_iid": "1"}, nil)
+	if err == nil {
+		t.Fatal("want transport error")
+	}
+	for _, leak := range []string{"secret-session", "sign=", srv.URL} {
+		if strings.Contains(err.Error(), leak) {
+			t.Errorf("error %q contains %q", err, leak)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package top
+
+import (
+	"errors"
+	"fmt"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/algs"
+	"strings"
+	"tbTool/pkg/gitlab.xfq.com/wpt-api/g-api/errorcode/base"
+)
+
+// TOP 网关返回的 error_response
+type TopError struct {
+	Code      int    `json:"code"`
+	Msg       string `json:"msg"`
+	SubCode   string `json:"sub_code"`
+	SubMsg    string `json:"sub_msg"`
+	RequestID string `json:"request_id"`
+}
+
+func (e *TopError) Error() string {
+	if e.SubCode != "" {
+		return fmt.Sprintf("top: code=%d msg=%s sub_code=%s sub_msg=%s request_id=%s", e.Code, e.Msg, e.SubCode, e.SubMsg, e.RequestID)
+	}
+	return fmt.Sprintf("top: code=%d msg=%s request_id=%s", e.Code, e.Msg, e.RequestID)
+}
+
+const (
+	CodeUserCallLimited     = 4
+	CodeSessionCallLimited  = 5
+	CodePartnerCallLimited  = 6
+	CodeAppCallLimited      = 7
+	CodeAppCallExceedsLimit = 8
+	CodeServiceUnavailable  = 10
+	CodeRemoteServiceError  = 15
+	CodeInvalidSignature    = 25
+	CodeMissingSession      = 26
+	CodeInvalidSession      = 27
+	CodeMissingArguments    = 40
+	CodeInvalidArguments    = 41
+	CodeParameterError      = 43
+	CodeSessionExpired      = 53
+)
+
+// isv.* 调用方(参数/业务规则)错误
+func (e *TopError) IsCallerError() bool {
+	if strings.HasPrefix(e.SubCode, "isv.") {
+		return true
+	}
+	return e.Code >= 21 && e.Code <= 47 && !e.IsInvalidSession()
+}
+
+// isp.* 平台错误, 一般可重试
+func (e *TopError) IsPlatformError() bool {
+	if strings.HasPrefix(e.SubCode, "isp.") {
+		return true
+	}
+	return e.SubCode == "" && (e.Code == CodeServiceUnavailable || e.Code == CodeRemoteServiceError)
+}
+
+// 流控
+func (e *TopError) IsThrottled() bool {
+	switch e.Code {
+	case CodeUserCallLimited, CodeSessionCallLimited, CodePartnerCallLimited, CodeAppCallLimited, CodeAppCallExceedsLimit:
+		return true
+	}
+	return strings.Contains(e.SubCode, "call-limited") || strings.HasPrefix(e.SubCode, "accesscontrol.limited")
+}
+
+// session 缺失/失效/过期, 需要重新授权
+func (e *TopError) IsInvalidSession() bool {
+	switch e.Code {
+	case CodeMissingSession, CodeInvalidSession, CodeSessionExpired:
+		return true
+	}
+	return strings.Contains(e.SubCode, "invalid-sessionkey") || strings.Contains(e.SubCode, "session-expired")
+}
+
+// 按顺序匹配, 第一条命中的规则生效
+var bizCodeRules = []struct {
+	match func(e *TopError) bool
+	code  int
+}{
+	{(*TopError).IsInvalidSession, base.NotLoginError},
+	{(*TopError).IsThrottled, base.Error},
+	{(*TopError).IsPlatformError, base.Error},
+	{func(e *TopError) bool {
+		return e.Code == CodeMissingArguments || strings.HasPrefix(e.SubCode, "isv.missing-parameter")
+	}, base.ParamError},
+	{func(e *TopError) bool {
+		return e.Code == CodeInvalidArguments || e.Code == CodeParameterError ||
+			strings.HasPrefix(e.SubCode, "isv.invalid-parameter") || strings.HasPrefix(e.SubCode, "isv.parameters-mismatch")
+	}, base.ParamIllegal},
+	{func(e *TopError) bool {
+		return strings.HasPrefix(e.SubCode, "isv.") && (strings.HasSuffix(e.SubCode, "-not-exist") || strings.HasSuffix(e.SubCode, "-not-found"))
+	}, base.MissingData},
+	{(*TopError).IsCallerError, base.DataStatus},
+}
+
+// 映射到 errorcode/base 业务码
+func (e *TopError) BizCode() int32 {
+	for _, rule := range bizCodeRules {
+		if rule.match(e) {
+			return int32(rule.code)
+		}
+	}
+	return base.Error
+}
+
+func (e *TopError) BizMsg() string {
+	detail := algs.FirstNotEmpty(e.SubMsg, e.Msg)
+	msg := base.ErrorMsg(int(e.BizCode()))
+	if strings.Contains(msg, "%s") {
+		return fmt.Sprintf(msg, detail)
+	}
+	if detail == "" {
+		return msg
+	}
+	return msg + ":" + detail
+}
+
+func AsTopError(err error) (*TopError, bool) {
+	var te *TopError
+	if errors.As(err, &te) {
+		return te, true
+	}
+	return nil, false
+}
+
+// 网关流控或本地配额耗尽
+func IsThrottled(err error) bool {
+	var qe *QuotaError
+	if errors.As(err, &qe) {
+		return true
+	}
+	te, ok := AsTopError(err)
+	return ok && te.IsThrottled()
+}
+
+func IsInvalidSession(err error) bool {
+	te, ok := AsTopError(err)
+	return ok && te.IsInvalidSession()
+}
+
+func IsCallerError(err error) bool {
+	te, ok := AsTopError(err)
+	return ok && te.IsCallerError()
+}
+
+func IsPlatformError(err error) bool {
+	te, ok := AsTopError(err)
+	return ok && te.IsPlatformError()
+}
@@ -0,0 +1,77 @@
+package top
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"tbTool/pkg/gitlab.xfq.com/wpt-api/g-api/errorcode/base"
+	"time"
+)
+
+// 调用前的配额检查, 所有副本共享同一份额度
+type Limiter interface {
+	// 取得一次调用额度, 额度不足时按配额模式等待或返回 *QuotaError
+	// session 为调用携带的卖家授权, 可为空
+	Wait(ctx context.Context, appKey, method, session string) error
+}
+
+type limiterHolder struct {
+	l Limiter
+}
+
+var limiter atomic.Value
+
+func init() {
+	limiter.Store(limiterHolder{})
+}
+
+// 设置所有客户端共用的限流器, nil 表示不限流
+func UseLimiter(l Limiter) {
+	limiter.Store(limiterHolder{l: l})
+}
+
+func currentLimiter() Limiter {
+	return limiter.Load().(limiterHolder).l
+}
+
+type QuotaMode int
+
+const (
+	// 沿用配额规则中的模式
+	QuotaDefault QuotaMode = iota
+	// 额度不足时等待, 最长等待时间由规则决定
+	QuotaWait
+	// 额度不足时立即返回 *QuotaError
+	QuotaFailFast
+)
+
+type quotaModeKey struct{}
+
+// 由调用方决定额度不足时等待还是快速失败, 如后台任务等待, 接口请求快速失败
+func WithQuotaMode(ctx context.Context, mode QuotaMode) context.Context {
+	return context.WithValue(ctx, quotaModeKey{}, mode)
+}
+
+func QuotaModeFrom(ctx context.Context) QuotaMode {
+	mode, _ := ctx.Value(quotaModeKey{}).(QuotaMode)
+	return mode
+}
+
+// 本地配额耗尽, 请求未发往网关
+type QuotaError struct {
+	AppKey     string
+	Method     string
+	RetryAfter time.Duration
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("top: quota exceeded app_key=%s method=%s retry_after=%s", e.AppKey, e.Method, e.RetryAfter)
+}
+
+func (e *QuotaError) BizCode() int32 {
+	return base.Error
+}
+
+func (e *QuotaError) BizMsg() string {
+	return fmt.Sprintf("%s:接口调用超出配额, 请%s后重试", base.ErrorMsg(base.Error), e.RetryAfter.Round(time.Second))
+}
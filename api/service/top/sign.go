@@ -0,0 +1,63 @@
+package top
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"sort"
+	"strings"
+)
+
+const (
+	SignMethodMD5        = "md5"
+	SignMethodHMAC       = "hmac"
+	SignMethodHMACSHA256 = "hmac-sha256"
+)
+
+// 淘宝开放平台签名算法
+// 1.所有系统参数和业务参数(sign及文件参数除外)按参数名ASCII升序排列
+// 2.按 key1value1key2value2... 拼接, 存在请求体时追加在末尾
+// 3.md5: 前后各拼接secret后取md5; hmac/hmac-sha256: 以secret为key摘要
+// 4.结果转为大写十六进制
+func Sign(params map[string]string, body []byte, secret, signMethod string) (string, error) {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		if k == "sign" {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buff strings.Builder
+	for _, k := range keys {
+		if params[k] == "" {
+			continue
+		}
+		buff.WriteString(k)
+		buff.WriteString(params[k])
+	}
+	if len(body) > 0 {
+		buff.Write(body)
+	}
+
+	var h hash.Hash
+	switch signMethod {
+	case SignMethodMD5, "":
+		h = md5.New()
+		h.Write([]byte(secret + buff.String() + secret))
+	case SignMethodHMAC:
+		h = hmac.New(md5.New, []byte(secret))
+		h.Write([]byte(buff.String()))
+	case SignMethodHMACSHA256:
+		h = hmac.New(sha256.New, []byte(secret))
+		h.Write([]byte(buff.String()))
+	default:
+		return "", fmt.Errorf("top: unsupported sign_method %q", signMethod)
+	}
+
+	return strings.ToUpper(hex.EncodeToString(h.Sum(nil))), nil
+}
@@ -0,0 +1,116 @@
+package top
+
+import "testing"
+
+// 开放平台文档中的签名示例参数, secret 为 helloworld
+func docParams(signMethod string) map[string]string {
+	return map[string]string{
+		"method":      "taobao.item.seller.get",
+		"app_key":     "12345678",
+		"session":     "test",
+		"timestamp":   "2016-01-01 12:00:00",
+		"format":      "json",
+		"v":           "2.0",
+		"sign_method": signMethod,
+		"fields":      "num_iid,title,nick,price,num",
+		"num_iid":     "11223344",
+	}
+}
+
+func TestSign(t *testing.T) {
+	cases := []struct {
+		name       string
+		params     map[string]string
+		body       []byte
+		signMethod string
+		want       string
+	}{
+		{
+			// 文档给出的结果
+			name:       "md5 doc example",
+			params:     docParams(SignMethodMD5),
+			signMethod: SignMethodMD5,
+			want:       "66987CB115214E59E6EC978214934FB8",
+		},
+		{
+			name:       "hmac doc params",
+			params:     docParams(SignMethodHMAC),
+			signMethod: SignMethodHMAC,
+			want:       "D56D7858309C31B6251083A874D48273",
+		},
+		{
+			name:       "hmac-sha256 doc params",
+			params:     docParams(SignMethodHMACSHA256),
+			signMethod: SignMethodHMACSHA256,
+			want:       "04DB15AD0774D5CFCE2C837DE43E3FCEA9011ED74F3038FB6AB5F3C4CEA119E8",
+		},
+		{
+			// 未指定 sign_method 按 md5
+			name:       "default md5",
+			params:     docParams(SignMethodMD5),
+			signMethod: "",
+			want:       "66987CB115214E59E6EC978214934FB8",
+		},
+		{
+			// sign 和空值参数不参与签名
+			name: "skip sign and empty values",
+			params: func() map[string]string {
+				p := docParams(SignMethodMD5)
+				p["sign"] = "SHOULD-BE-IGNORED"
+				p["empty"] = ""
+				return p
+			}(),
+			signMethod: SignMethodMD5,
+			want:       "66987CB115214E59E6EC978214934FB8",
+		},
+		{
+			// 请求体追加在参数之后
+			name: "body appended",
+			params: map[string]string{
+				"app_key": "12345678",
+				"method":  "taobao.item.quantity.update",
+				"v":       "2.0",
+			},
+			body:       []byte("num_iid=1&quantity=2"),
+			signMethod: SignMethodMD5,
+			want:       "524A73A0B231C43B23130ED976A6B4E6",
+		},
+		{
+			// 按字节序排列: 大写先于小写, 前缀先于更长的key, '_' 先于小写字母
+			name: "byte order",
+			params: map[string]string{
+				"ab":  "4",
+				"a_b": "3",
+				"a":   "2",
+				"B":   "z1",
+			},
+			signMethod: SignMethodMD5,
+			want:       "3B4E5AE822B51EF9D79C5C0F958ACDEE",
+		},
+		{
+			// 值按 utf-8 原样参与签名
+			name:       "utf-8 value",
+			params:     map[string]string{"k": "中文值"},
+			signMethod: SignMethodMD5,
+			want:       "156BAD32DAE9CFA75A672EE7ECFAD483",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := Sign(c.params, c.body, "helloworld", c.signMethod)
+			if err != nil {
+				t.Fatalf("Sign: %v", err)
+			}
+			if got != c.want {
+				t.Errorf("Sign = %s, want %s", got, c.want)
+			}
+		})
+	}
+}
+
+func TestSignUnsupportedMethod(t *testing.T) {
+	if _, err := Sign(docParams("sha1"), nil, "helloworld", "sha1"); err == nil {
+		t.Fatal("want error for unsupported sign_method")
+	}
+}
@@ -0,0 +1,333 @@
+// 本地 TOP 网关替身, 校验签名并按方法返回预置响应, 可注入错误、流控和延迟
+package toptest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"tbTool/api/service/top"
+	"time"
+)
+
+const (
+	RestPath  = "/router/rest"
+	BatchPath = "/router/batch"
+)
+
+// 按请求参数生成 <method>_response 的内容, 返回 *top.TopError 时输出 error_response
+type HandlerFunc func(params url.Values) (interface{}, error)
+
+// 一次收到的调用
+type Call struct {
+	Method string
+	Params url.Values
+	// 上传的文件, 字段名 -> 内容
+	Files map[string][]byte
+	Batch bool
+}
+
+type fault struct {
+	err   *top.TopError
+	times int
+}
+
+type Server struct {
+	appKey    string
+	appSecret string
+	srv       *httptest.Server
+
+	mu       sync.Mutex
+	handlers map[string]HandlerFunc
+	faults   map[string][]*fault
+	delays   map[string]time.Duration
+	calls    []Call
+}
+
+// 只接受 appKey/appSecret 签名的请求
+func NewServer(appKey, appSecret string) *Server {
+	s := &Server{
+		appKey:    appKey,
+		appSecret: appSecret,
+		handlers:  map[string]HandlerFunc{},
+		faults:    map[string][]*fault{},
+		delays:    map[string]time.Duration{},
+	}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// 普通调用网关地址
+func (s *Server) URL() string {
+	return s.srv.URL + RestPath
+}
+
+// 批量网关地址
+func (s *Server) BatchURL() string {
+	return s.srv.URL + BatchPath
+}
+
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// 固定响应, v 为 <method>_response 的内容
+func (s *Server) Fixture(method string, v interface{}) {
+	s.HandleFunc(method, func(url.Values) (interface{}, error) {
+		return v, nil
+	})
+}
+
+// 从 JSON 文本加载固定响应
+func (s *Server) FixtureJSON(method, data string) {
+	s.Fixture(method, json.RawMessage(data))
+}
+
+func (s *Server) HandleFunc(method string, fn HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[method] = fn
+}
+
+// 之后 times 次调用返回 te, times <= 0 表示一直返回
+func (s *Server) Fail(method string, te *top.TopError, times int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults[method] = append(s.faults[method], &fault{err: te, times: times})
+}
+
+// 之后 times 次调用返回应用级流控错误
+func (s *Server) Throttle(method string, times int) {
+	s.Fail(method, &top.TopError{
+		Code:    top.CodeAppCallLimited,
+		Msg:     "App Call Limited",
+		SubCode: "accesscontrol.limited-by-app-access-count",
+		SubMsg:  "This ban will last for 1 more seconds",
+	}, times)
+}
+
+// 每次调用 method 前等待 d, method 为空时作用于所有方法
+func (s *Server) Delay(method string, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delays[method] = d
+}
+
+// 清除预置响应、注入的错误和延迟, 保留调用记录
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers = map[string]HandlerFunc{}
+	s.faults = map[string][]*fault{}
+	s.delays = map[string]time.Duration{}
+}
+
+// 已收到的调用, method 为空时返回全部
+func (s *Server) Calls(method string) []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var calls []Call
+	for _, c := range s.calls {
+		if method == "" || c.Method == method {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+
+	system, body, files, err := readRequest(r)
+	if err != nil {
+		writeError(w, &top.TopError{Code: top.CodeMissingArguments, Msg: err.Error()})
+		return
+	}
+	if te := s.verify(system, body); te != nil {
+		writeError(w, te)
+		return
+	}
+
+	if r.URL.Path == BatchPath {
+		s.serveBatch(w, r, body)
+		return
+	}
+
+	method := system.Get("method")
+	s.record(Call{Method: method, Params: system, Files: files})
+	w.Write(s.respond(r, method, system))
+}
+
+// 批量请求逐个子调用处理, 按原顺序以分隔符拼接
+func (s *Server) serveBatch(w http.ResponseWriter, r *http.Request, body []byte) {
+	var parts []string
+	for _, line := range strings.Split(string(body), top.BatchSeparator) {
+		params, err := url.ParseQuery(line)
+		if err != nil {
+			parts = append(parts, string(errorBody(&top.TopError{Code: top.CodeInvalidArguments, Msg: err.Error()})))
+			continue
+		}
+		method := params.Get("method")
+		s.record(Call{Method: method, Params: params, Batch: true})
+		parts = append(parts, string(s.respond(r, method, params)))
+	}
+	w.Write([]byte(strings.Join(parts, top.BatchSeparator)))
+}
+
+func (s *Server) respond(r *http.Request, method string, params url.Values) []byte {
+	s.mu.Lock()
+	d, ok := s.delays[method]
+	if !ok {
+		d = s.delays[""]
+	}
+	te := s.nextFault(method)
+	fn := s.handlers[method]
+	s.mu.Unlock()
+
+	if d > 0 {
+		select {
+		case <-time.After(d):
+		case <-r.Context().Done():
+			return nil
+		}
+	}
+	if te != nil {
+		return errorBody(te)
+	}
+	if fn == nil {
+		return errorBody(&top.TopError{Code: 22, Msg: "Invalid method", SubMsg: method})
+	}
+
+	v, err := fn(params)
+	if err != nil {
+		if te, ok := top.AsTopError(err); ok {
+			return errorBody(te)
+		}
+		return errorBody(&top.TopError{Code: top.CodeRemoteServiceError, Msg: "Remote service error", SubCode: "isp.unknown-error", SubMsg: err.Error()})
+	}
+	data, err := json.Marshal(map[string]interface{}{top.ResponseKey(method): v})
+	if err != nil {
+		return errorBody(&top.TopError{Code: top.CodeRemoteServiceError, Msg: err.Error()})
+	}
+	return data
+}
+
+// 调用方已持有锁
+func (s *Server) nextFault(method string) *top.TopError {
+	list := s.faults[method]
+	if len(list) == 0 {
+		return nil
+	}
+	f := list[0]
+	if f.times > 0 {
+		f.times--
+		if f.times == 0 {
+			s.faults[method] = list[1:]
+		}
+	}
+	return f.err
+}
+
+func (s *Server) record(c Call) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, c)
+}
+
+// 与客户端相同的签名算法, 文件参数不参与签名, 批量请求体参与签名
+func (s *Server) verify(params url.Values, body []byte) *top.TopError {
+	if params.Get("app_key") != s.appKey {
+		return &top.TopError{Code: 29, Msg: "Invalid app Key"}
+	}
+	flat := make(map[string]string, len(params))
+	for k := range params {
+		flat[k] = params.Get(k)
+	}
+	expected, err := top.Sign(flat, body, s.appSecret, params.Get("sign_method"))
+	if err != nil {
+		return &top.TopError{Code: top.CodeInvalidArguments, Msg: err.Error()}
+	}
+	if expected != params.Get("sign") {
+		return &top.TopError{Code: top.CodeInvalidSignature, Msg: "Invalid signature"}
+	}
+	return nil
+}
+
+// 返回参与签名的参数、批量请求体和上传的文件
+func readRequest(r *http.Request) (url.Values, []byte, map[string][]byte, error) {
+	params := url.Values{}
+	for k, v := range r.URL.Query() {
+		params[k] = v
+	}
+	if r.Method != http.MethodPost {
+		return params, nil, nil, nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		if err := r.ParseForm(); err != nil {
+			return nil, nil, nil, err
+		}
+		for k, v := range r.PostForm {
+			params[k] = v
+		}
+		return params, nil, nil, nil
+	case "multipart/form-data":
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			return nil, nil, nil, err
+		}
+		for k, v := range r.MultipartForm.Value {
+			params[k] = v
+		}
+		files := map[string][]byte{}
+		for field, fhs := range r.MultipartForm.File {
+			f, err := fhs[0].Open()
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			data, err := ioutil.ReadAll(f)
+			f.Close()
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			files[field] = data
+		}
+		return params, nil, files, nil
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return params, body, nil, nil
+}
+
+func writeError(w http.ResponseWriter, te *top.TopError) {
+	w.Write(errorBody(te))
+}
+
+func errorBody(te *top.TopError) []byte {
+	if te.RequestID == "" {
+		te = &top.TopError{Code: te.Code, Msg: te.Msg, SubCode: te.SubCode, SubMsg: te.SubMsg, RequestID: requestID()}
+	}
+	data, _ := json.Marshal(map[string]*top.TopError{"error_response": te})
+	return data
+}
+
+var (
+	idMu   sync.Mutex
+	nextID int64
+)
+
+func requestID() string {
+	idMu.Lock()
+	defer idMu.Unlock()
+	nextID++
+	return fmt.Sprintf("toptest-%d", nextID)
+}
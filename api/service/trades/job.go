@@ -0,0 +1,76 @@
+package trades
+
+import (
+	"context"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/conf"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/grpool"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/logger"
+	"sync"
+	"tbTool/api/service/shop"
+	"tbTool/api/service/top"
+	"time"
+)
+
+const (
+	defaultSyncInterval = 5 * time.Minute
+	syncTimeout         = 5 * time.Minute
+)
+
+// 定时为所有店铺执行订单增量同步
+type SyncJob struct {
+	ts   TradeService
+	stop chan struct{}
+	once sync.Once
+}
+
+func NewSyncJob(ts TradeService) *SyncJob {
+	return &SyncJob{
+		ts:   ts,
+		stop: make(chan struct{}),
+	}
+}
+
+func (j *SyncJob) Start() error {
+	interval := conf.GetDurationFormConfigFile("trades.sync_interval")
+	if interval <= 0 {
+		interval = defaultSyncInterval
+	}
+
+	return grpool.Submit(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-j.stop:
+				return
+			case <-ticker.C:
+				j.runOnce()
+			}
+		}
+	})
+}
+
+func (j *SyncJob) Stop() error {
+	j.once.Do(func() { close(j.stop) })
+	return nil
+}
+
+func (j *SyncJob) runOnce() {
+	shop.Range(func(s *shop.Shop) bool {
+		// 后台任务不赶时间, 配额不足时等待而不是失败
+		ctx := top.WithQuotaMode(shop.NewContext(context.Background(), s), top.QuotaWait)
+		ctx, cancel := context.WithTimeout(ctx, syncTimeout)
+		defer cancel()
+
+		result, err := j.ts.SyncSold(ctx)
+		switch err {
+		case nil:
+			logger.Infof("trades sync shop %s done: %d trades until %s", s.ID, result.Trades, result.To)
+		case ErrSyncRunning:
+			logger.Debugf("trades sync shop %s skipped: %v", s.ID, err)
+		default:
+			logger.Errorf("trades sync shop %s error: %v", s.ID, err)
+		}
+		return true
+	})
+}
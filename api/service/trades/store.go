@@ -0,0 +1,142 @@
+package trades
+
+import (
+	"context"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/orm"
+	"sync"
+	"tbTool/api/service/top"
+	"tbTool/api/tools/common"
+	"time"
+)
+
+// 订单落库记录, 子订单等完整数据保存在 Raw 中
+type TradeRecord struct {
+	Tid        int64  `gorm:"primary_key;auto_increment:false"`
+	ShopID     string `gorm:"type:varchar(64);index"`
+	Status     string `gorm:"type:varchar(64)"`
+	BuyerNick  string `gorm:"type:varchar(128)"`
+	SellerNick string `gorm:"type:varchar(128)"`
+	Payment    string `gorm:"type:varchar(32)"`
+	TotalFee   string `gorm:"type:varchar(32)"`
+	PostFee    string `gorm:"type:varchar(32)"`
+	Created    time.Time
+	Modified   time.Time `gorm:"index"`
+	PayTime    *time.Time
+	EndTime    *time.Time
+	Raw        string `gorm:"type:mediumtext"`
+	UpdatedAt  time.Time
+}
+
+func (TradeRecord) TableName() string {
+	return "tb_trade"
+}
+
+// 各店铺各类同步任务的高水位
+type SyncCheckpoint struct {
+	ShopID    string `gorm:"primary_key;type:varchar(64)"`
+	Kind      string `gorm:"primary_key;type:varchar(32)"`
+	Position  time.Time
+	UpdatedAt time.Time
+}
+
+func (SyncCheckpoint) TableName() string {
+	return "tb_sync_checkpoint"
+}
+
+const checkpointKind = "trades_sold"
+
+var (
+	migrateMu sync.Mutex
+	migrated  bool
+)
+
+func getDB(ctx context.Context) (*gorm.DB, error) {
+	cli, err := orm.GetClient(ctx, common.OrmName)
+	if err != nil {
+		return nil, err
+	}
+
+	migrateMu.Lock()
+	defer migrateMu.Unlock()
+	if !migrated {
+		if err := cli.AutoMigrate(&TradeRecord{}, &SyncCheckpoint{}).Error; err != nil {
+			return nil, err
+		}
+		migrated = true
+	}
+	return cli.DB, nil
+}
+
+func toRecord(shopID string, t *Trade) (*TradeRecord, error) {
+	r := &TradeRecord{
+		Tid:        t.Tid,
+		ShopID:     shopID,
+		Status:     t.Status,
+		BuyerNick:  t.BuyerNick,
+		SellerNick: t.SellerNick,
+		Payment:    t.Payment,
+		TotalFee:   t.TotalFee,
+		PostFee:    t.PostFee,
+		Raw:        rawJSON(t),
+	}
+	var err error
+	if r.Created, err = top.ParseTime(t.Created); err != nil {
+		return nil, fmt.Errorf("trades: tid %d invalid created %q: %v", t.Tid, t.Created, err)
+	}
+	if r.Modified, err = top.ParseTime(t.Modified); err != nil {
+		return nil, fmt.Errorf("trades: tid %d invalid modified %q: %v", t.Tid, t.Modified, err)
+	}
+	// 未付款/未结束的交易没有对应时间
+	if r.PayTime, err = optionalTime(t.PayTime); err != nil {
+		return nil, fmt.Errorf("trades: tid %d invalid pay_time %q: %v", t.Tid, t.PayTime, err)
+	}
+	if r.EndTime, err = optionalTime(t.EndTime); err != nil {
+		return nil, fmt.Errorf("trades: tid %d invalid end_time %q: %v", t.Tid, t.EndTime, err)
+	}
+	return r, nil
+}
+
+func optionalTime(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	t, err := top.ParseTime(s)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// 按 tid 插入或更新
+func upsertTrades(db *gorm.DB, shopID string, trades []Trade) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for i := range trades {
+			r, err := toRecord(shopID, &trades[i])
+			if err != nil {
+				return err
+			}
+			if err := tx.Save(r).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func loadCheckpoint(db *gorm.DB, shopID, kind string) (time.Time, bool, error) {
+	var cp SyncCheckpoint
+	err := db.Where("shop_id = ? AND kind = ?", shopID, kind).First(&cp).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return cp.Position, true, nil
+}
+
+func saveCheckpoint(db *gorm.DB, shopID, kind string, position time.Time) error {
+	return db.Save(&SyncCheckpoint{ShopID: shopID, Kind: kind, Position: position}).Error
+}
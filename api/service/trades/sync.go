@@ -0,0 +1,139 @@
+package trades
+
+import (
+	"context"
+	"errors"
+	"github.com/jinzhu/gorm"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/grpool"
+	dredis "gitlab.xfq.com/tech-lab/dionysus/pkg/redis"
+	"tbTool/api/service/shop"
+	"tbTool/api/tools/common"
+	"time"
+)
+
+const (
+	// 首次同步回溯的时间
+	initialLookback = time.Hour
+	// 订单变更到可查询有延迟, 结束时间留出缓冲
+	endDelay = time.Minute
+
+	syncLockPrefix = "tbtool:trades:sync:"
+	syncLockTTL    = 10 * time.Minute
+	// 单次同步的最长时间, 短于锁有效期, 避免锁过期后与其他实例并发同步
+	maxSyncDuration = syncLockTTL - time.Minute
+)
+
+var ErrSyncRunning = errors.New("trades: sync is already running for this shop")
+
+func (ts *TradeServiceImpl) SyncSold(ctx context.Context) (*SyncResult, error) {
+	lock, err := lockSync(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Release()
+	return ts.syncSold(ctx)
+}
+
+func (ts *TradeServiceImpl) StartSyncSold(ctx context.Context, done func(*SyncResult, error)) error {
+	lock, err := lockSync(ctx)
+	if err != nil {
+		return err
+	}
+	err = grpool.Submit(func() {
+		defer lock.Release()
+		done(ts.syncSold(ctx))
+	})
+	if err != nil {
+		lock.Release()
+	}
+	return err
+}
+
+// 多副本/定时任务与手动触发互斥
+func lockSync(ctx context.Context) (*common.Lock, error) {
+	s, ok := shop.FromContext(ctx)
+	if !ok {
+		return nil, shop.ErrMissingShop
+	}
+	rc, err := dredis.GetClient(ctx, common.RedisName)
+	if err != nil {
+		return nil, err
+	}
+	lock, ok, err := common.TryLock(rc, syncLockPrefix+s.ID, syncLockTTL)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrSyncRunning
+	}
+	return lock, nil
+}
+
+// 调用方已持有同步锁
+func (ts *TradeServiceImpl) syncSold(ctx context.Context) (*SyncResult, error) {
+	s, ok := shop.FromContext(ctx)
+	if !ok {
+		return nil, shop.ErrMissingShop
+	}
+	ctx, cancel := context.WithTimeout(ctx, maxSyncDuration)
+	defer cancel()
+
+	session, err := ts.ss.Session(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	db, err := getDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	from, ok, err := loadCheckpoint(db, s.ID, checkpointKind)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		from = now.Add(-initialLookback)
+	}
+	end := now.Add(-endDelay)
+
+	result := &SyncResult{ShopID: s.ID, From: from, To: from}
+	for from.Before(end) {
+		to := from.Add(maxIncrementWindow)
+		if to.After(end) {
+			to = end
+		}
+
+		n, err := ts.syncWindow(ctx, db, s.ID, session, from, to)
+		result.Trades += n
+		if err != nil {
+			return result, err
+		}
+		if err := saveCheckpoint(db, s.ID, checkpointKind, to); err != nil {
+			return result, err
+		}
+		from = to
+		result.To = to
+	}
+
+	return result, nil
+}
+
+// 拉取一个时间窗口内的全部分页并落库
+func (ts *TradeServiceImpl) syncWindow(ctx context.Context, db *gorm.DB, shopID, session string, from, to time.Time) (int, error) {
+	var total int
+	for pageNo := 1; ; pageNo++ {
+		resp, err := ts.soldIncrementPage(ctx, session, from, to, pageNo)
+		if err != nil {
+			return total, err
+		}
+		if err := upsertTrades(db, shopID, resp.Trades.Trade); err != nil {
+			return total, err
+		}
+		total += len(resp.Trades.Trade)
+
+		if !resp.HasNext || len(resp.Trades.Trade) == 0 {
+			return total, nil
+		}
+	}
+}
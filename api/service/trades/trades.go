@@ -0,0 +1,238 @@
+package trades
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"tbTool/api/service/oauth"
+	"tbTool/api/service/top"
+	"time"
+)
+
+const (
+	MethodTradesSoldIncrementGet = "taobao.trades.sold.increment.get"
+	MethodTradeFullinfoGet       = "taobao.trade.fullinfo.get"
+	MethodTradesSoldGet          = "taobao.trades.sold.get"
+
+	TradeFields = "tid,status,buyer_nick,seller_nick,payment,total_fee,post_fee,created,modified,pay_time,end_time," +
+		"receiver_name,receiver_state,receiver_city,receiver_district,receiver_address,receiver_mobile," +
+		"orders.oid,orders.num_iid,orders.sku_id,orders.title,orders.price,orders.num,orders.payment," +
+		"orders.status,orders.refund_status,orders.outer_iid,orders.outer_sku_id"
+
+	DefaultFullinfoFields = TradeFields + ",shipping_type,consign_time,buyer_message,seller_memo,seller_flag," +
+		"buyer_rate,seller_rate,discount_fee,adjust_fee,received_payment,orders.discount_fee,orders.adjust_fee," +
+		"orders.sku_properties_name,orders.pic_path,orders.refund_id,orders.logistics_company,orders.invoice_no"
+
+	DefaultSoldPageSize = 40
+	MaxSoldPageSize     = 100
+
+	// 增量接口单页最大条数
+	IncrementPageSize = 100
+	// 增量接口单次查询的最大时间跨度
+	maxIncrementWindow = 24 * time.Hour
+)
+
+// 交易详情可选字段
+var FullinfoFieldList = strings.Split(DefaultFullinfoFields, ",")
+
+type Order struct {
+	Oid          int64  `json:"oid"`
+	NumIid       int64  `json:"num_iid"`
+	SkuID        string `json:"sku_id,omitempty"`
+	Title        string `json:"title"`
+	Price        string `json:"price"`
+	Num          int64  `json:"num"`
+	Payment      string `json:"payment"`
+	Status       string `json:"status"`
+	RefundStatus string `json:"refund_status,omitempty"`
+	OuterIid     string `json:"outer_iid,omitempty"`
+	OuterSkuID   string `json:"outer_sku_id,omitempty"`
+
+	DiscountFee       string `json:"discount_fee,omitempty"`
+	AdjustFee         string `json:"adjust_fee,omitempty"`
+	SkuPropertiesName string `json:"sku_properties_name,omitempty"`
+	PicPath           string `json:"pic_path,omitempty"`
+	RefundID          int64  `json:"refund_id,omitempty"`
+	LogisticsCompany  string `json:"logistics_company,omitempty"`
+	InvoiceNo         string `json:"invoice_no,omitempty"`
+}
+
+type Trade struct {
+	Tid              int64  `json:"tid"`
+	Status           string `json:"status"`
+	BuyerNick        string `json:"buyer_nick"`
+	SellerNick       string `json:"seller_nick"`
+	Payment          string `json:"payment"`
+	TotalFee         string `json:"total_fee"`
+	PostFee          string `json:"post_fee"`
+	Created          string `json:"created"`
+	Modified         string `json:"modified"`
+	PayTime          string `json:"pay_time,omitempty"`
+	EndTime          string `json:"end_time,omitempty"`
+	ReceiverName     string `json:"receiver_name,omitempty"`
+	ReceiverState    string `json:"receiver_state,omitempty"`
+	ReceiverCity     string `json:"receiver_city,omitempty"`
+	ReceiverDistrict string `json:"receiver_district,omitempty"`
+	ReceiverAddress  string `json:"receiver_address,omitempty"`
+	ReceiverMobile   string `json:"receiver_mobile,omitempty"`
+	ShippingType     string `json:"shipping_type,omitempty"`
+	ConsignTime      string `json:"consign_time,omitempty"`
+	BuyerMessage     string `json:"buyer_message,omitempty"`
+	SellerMemo       string `json:"seller_memo,omitempty"`
+	SellerFlag       int    `json:"seller_flag,omitempty"`
+	BuyerRate        bool   `json:"buyer_rate,omitempty"`
+	SellerRate       bool   `json:"seller_rate,omitempty"`
+	DiscountFee      string `json:"discount_fee,omitempty"`
+	AdjustFee        string `json:"adjust_fee,omitempty"`
+	ReceivedPayment  string `json:"received_payment,omitempty"`
+	Orders           struct {
+		Order []Order `json:"order"`
+	} `json:"orders"`
+}
+
+type TradesSoldIncrementGetResponse struct {
+	Trades struct {
+		Trade []Trade `json:"trade"`
+	} `json:"trades"`
+	TotalResults int  `json:"total_results"`
+	HasNext      bool `json:"has_next"`
+}
+
+// 一次同步的结果
+type SyncResult struct {
+	ShopID string    `json:"shop_id"`
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+	Trades int       `json:"trades"`
+}
+
+type TradesSoldGetResponse struct {
+	Trades struct {
+		Trade []Trade `json:"trade"`
+	} `json:"trades"`
+	TotalResults int  `json:"total_results"`
+	HasNext      bool `json:"has_next"`
+}
+
+type SoldQuery struct {
+	// 交易状态, 如 WAIT_SELLER_SEND_GOODS, 为空时查询全部
+	Status       string
+	StartCreated time.Time
+	EndCreated   time.Time
+	PageNo       int
+	PageSize     int
+}
+
+type TradesPage struct {
+	Trades       []Trade `json:"trades"`
+	PageNo       int     `json:"page_no"`
+	PageSize     int     `json:"page_size"`
+	TotalResults int     `json:"total_results"`
+	HasNext      bool    `json:"has_next"`
+}
+
+type TradeFullinfoGetResponse struct {
+	Trade *Trade `json:"trade"`
+}
+
+type TradeService interface {
+	// 从上次同步位置增量拉取订单并写入 MySQL
+	SyncSold(ctx context.Context) (*SyncResult, error)
+	// 抢到同步锁后在后台执行同步, 结束时回调 done; 已有同步在执行时返回 ErrSyncRunning
+	StartSyncSold(ctx context.Context, done func(*SyncResult, error)) error
+	// 获取单笔交易的详细信息
+	TradeFullinfoGet(ctx context.Context, session string, tid int64, fields string) (*Trade, error)
+	// 按创建时间分页查询卖家已卖出的交易
+	TradesSoldGet(ctx context.Context, session string, q SoldQuery) (*TradesPage, error)
+}
+
+type TradeServiceImpl struct {
+	cli top.Caller
+	ss  oauth.SessionService
+}
+
+func NewTradeServiceImpl(cli top.Caller, ss oauth.SessionService) TradeService {
+	return &TradeServiceImpl{
+		cli: cli,
+		ss:  ss,
+	}
+}
+
+func (ts *TradeServiceImpl) soldIncrementPage(ctx context.Context, session string, from, to time.Time, pageNo int) (*TradesSoldIncrementGetResponse, error) {
+	params := top.Params{}.
+		Set("session", session).
+		Set("fields", TradeFields).
+		Set("start_modified", from).
+		Set("end_modified", to).
+		Set("page_no", pageNo).
+		Set("page_size", IncrementPageSize).
+		Set("use_has_next", true)
+
+	var resp TradesSoldIncrementGetResponse
+	if err := ts.cli.Call(ctx, MethodTradesSoldIncrementGet, params, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (ts *TradeServiceImpl) TradeFullinfoGet(ctx context.Context, session string, tid int64, fields string) (*Trade, error) {
+	if fields == "" {
+		fields = DefaultFullinfoFields
+	}
+	params := top.Params{}.
+		Set("session", session).
+		Set("fields", fields).
+		Set("tid", tid)
+
+	var resp TradeFullinfoGetResponse
+	if err := ts.cli.Call(ctx, MethodTradeFullinfoGet, params, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Trade, nil
+}
+
+func (ts *TradeServiceImpl) TradesSoldGet(ctx context.Context, session string, q SoldQuery) (*TradesPage, error) {
+	if q.PageSize <= 0 {
+		q.PageSize = DefaultSoldPageSize
+	}
+	if q.PageSize > MaxSoldPageSize {
+		q.PageSize = MaxSoldPageSize
+	}
+	if q.PageNo <= 0 {
+		q.PageNo = 1
+	}
+
+	params := top.Params{}.
+		Set("session", session).
+		Set("fields", TradeFields).
+		Set("page_no", q.PageNo).
+		Set("page_size", q.PageSize).
+		Set("use_has_next", true)
+	if q.Status != "" {
+		params.Set("status", q.Status)
+	}
+	if !q.StartCreated.IsZero() {
+		params.Set("start_created", q.StartCreated)
+	}
+	if !q.EndCreated.IsZero() {
+		params.Set("end_created", q.EndCreated)
+	}
+
+	var resp TradesSoldGetResponse
+	if err := ts.cli.Call(ctx, MethodTradesSoldGet, params, &resp); err != nil {
+		return nil, err
+	}
+
+	return &TradesPage{
+		Trades:       resp.Trades.Trade,
+		PageNo:       q.PageNo,
+		PageSize:     q.PageSize,
+		TotalResults: resp.TotalResults,
+		HasNext:      resp.HasNext,
+	}, nil
+}
+
+func rawJSON(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
@@ -0,0 +1,149 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"tbTool/pkg"
+	"tbTool/pkg/gitlab.xfq.com/wpt-api/g-api/errorcode/base"
+)
+
+// 参数校验错误, Field 为 json 字段名
+type FieldError struct {
+	Field string
+	Rule  string
+}
+
+func (e *FieldError) Error() string {
+	if e.Rule == "" {
+		return e.Field
+	}
+	return e.Field + " " + e.Rule
+}
+
+func Illegal(field, rule string) *FieldError {
+	return &FieldError{Field: field, Rule: rule}
+}
+
+// 请求结构体实现此接口做 tag 无法表达的校验, 如字段白名单; 返回 *FieldError 时给出字段级提示
+type Validatable interface {
+	Validate() error
+}
+
+// 校验错误使用 json 字段名
+func init() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(f reflect.StructField) string {
+			name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+			if name == "" || name == "-" {
+				return f.Name
+			}
+			return name
+		})
+	}
+}
+
+// 绑定 query 和 body(json 或表单), 同名参数 body 优先, 空 body 视为未传
+// 绑定后按 binding tag 校验, 再调用 Validate; 失败时返回 ParamIllegal 响应
+//
+//	if r, ok := common.Bind(c, &req); !ok {
+//		return r
+//	}
+func Bind(c *gin.Context, req interface{}) (pkg.Render, bool) {
+	if err := bind(c, req); err != nil {
+		return ResParamIllegal(err.Error()), false
+	}
+	if err := binding.Validator.ValidateStruct(req); err != nil {
+		return ResParamIllegal(validationMsg(err)), false
+	}
+	if v, ok := req.(Validatable); ok {
+		if err := v.Validate(); err != nil {
+			return ResParamIllegal(err.Error()), false
+		}
+	}
+	return nil, true
+}
+
+func ResParamIllegal(msg string) pkg.Render {
+	return ResErr(base.ParamIllegal, fmt.Sprintf(base.ErrorMsg(base.ParamIllegal), msg))
+}
+
+func bind(c *gin.Context, req interface{}) error {
+	if err := binding.MapFormWithTag(req, c.Request.URL.Query(), "form"); err != nil {
+		return err
+	}
+	if c.Request.Body == nil || c.Request.Method == "GET" {
+		return nil
+	}
+
+	switch c.ContentType() {
+	case binding.MIMEJSON:
+		data, err := ioutil.ReadAll(c.Request.Body)
+		if err != nil {
+			return err
+		}
+		if len(strings.TrimSpace(string(data))) == 0 {
+			return nil
+		}
+		if err := json.Unmarshal(data, req); err != nil {
+			var te *json.UnmarshalTypeError
+			if errors.As(err, &te) && te.Field != "" {
+				return Illegal(te.Field, "type")
+			}
+			return Illegal("body", "json")
+		}
+		return nil
+	case binding.MIMEPOSTForm:
+		if err := c.Request.ParseForm(); err != nil {
+			return err
+		}
+		return binding.MapFormWithTag(req, c.Request.PostForm, "form")
+	case binding.MIMEMultipartPOSTForm:
+		if err := c.Request.ParseMultipartForm(32 << 20); err != nil {
+			return err
+		}
+		return binding.MapFormWithTag(req, c.Request.MultipartForm.Value, "form")
+	}
+	return nil
+}
+
+// 取第一条校验失败, 如 "page_size max=200", "items[0].num_iid gt=0"
+func validationMsg(err error) string {
+	var ves validator.ValidationErrors
+	if !errors.As(err, &ves) || len(ves) == 0 {
+		return err.Error()
+	}
+	fe := ves[0]
+	field := fe.Namespace()
+	if i := strings.Index(field, "."); i >= 0 {
+		field = field[i+1:]
+	}
+	rule := fe.Tag()
+	if fe.Param() != "" {
+		rule += "=" + fe.Param()
+	}
+	return Illegal(field, rule).Error()
+}
+
+// 逗号分隔的取值必须都在 allowed 中
+func CheckList(field, value string, allowed []string) error {
+	if value == "" {
+		return nil
+	}
+	set := make(map[string]bool, len(allowed))
+	for _, a := range allowed {
+		set[a] = true
+	}
+	for _, v := range strings.Split(value, ",") {
+		if !set[strings.TrimSpace(v)] {
+			return Illegal(field, strings.TrimSpace(v))
+		}
+	}
+	return nil
+}
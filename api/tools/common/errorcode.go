@@ -0,0 +1,26 @@
+package common
+
+import "tbTool/pkg/gitlab.xfq.com/wpt-api/g-api/errorcode/base"
+
+// tbTool 自有错误码, 共享的 base 错误码由公共库维护, 这里不占用其号段
+const (
+	SignInvalid    = 410001
+	RequestExpired = 410002
+	RequestReplay  = 410003
+	CallerUnknown  = 410004
+)
+
+var errorMsg = map[int]string{
+	SignInvalid:    "签名校验失败",
+	RequestExpired: "请求时间戳超出允许范围",
+	RequestReplay:  "请求重复提交",
+	CallerUnknown:  "调用方不存在或已禁用",
+}
+
+// 本地错误码的提示, 其余按 base 错误码查找
+func ErrorMsg(code int) string {
+	if msg, ok := errorMsg[code]; ok {
+		return msg
+	}
+	return base.ErrorMsg(code)
+}
@@ -0,0 +1,40 @@
+package common
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/go-redis/redis/v7"
+	"time"
+)
+
+// 仅当锁仍属于自己时删除
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// redis 互斥锁, 值为随机 token, 锁过期后不会误删其他实例抢到的锁
+type Lock struct {
+	rc    *redis.Client
+	key   string
+	token string
+}
+
+// 抢锁失败时返回 false
+func TryLock(rc *redis.Client, key string, ttl time.Duration) (*Lock, bool, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, false, err
+	}
+	l := &Lock{rc: rc, key: key, token: hex.EncodeToString(b)}
+	ok, err := rc.SetNX(key, l.token, ttl).Result()
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return l, true, nil
+}
+
+func (l *Lock) Release() error {
+	return releaseScript.Run(l.rc, []string{l.key}, l.token).Err()
+}
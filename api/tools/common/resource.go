@@ -0,0 +1,10 @@
+package common
+
+const (
+	// watch.redis 下的 redis 实例名
+	RedisName = "watch.redis.tbtool"
+	// watch.mysql 下的 mysql 实例名
+	OrmName = "watch.mysql.tbtool"
+	// watch.rabbitmq 下的 rabbitmq 实例名
+	RabbitName = "watch.rabbitmq.tbtool"
+)
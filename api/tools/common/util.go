@@ -1,10 +1,22 @@
 package common
 
 import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/logger"
+	"tbTool/api/tools/toptime"
 	"tbTool/pkg"
+	"tbTool/pkg/gitlab.xfq.com/wpt-api/g-api/errorcode/base"
 	"time"
 )
 
+// 可映射为业务错误码的错误, 例如 top.TopError
+type BizError interface {
+	error
+	BizCode() int32
+	BizMsg() string
+}
+
 func ResErr(code int32, msg string) pkg.Render {
 	return pkg.JSON{
 		Data: &Response{
@@ -16,6 +28,16 @@ func ResErr(code int32, msg string) pkg.Render {
 	}
 }
 
+// 按错误类型返回业务码, 非业务错误统一返回系统异常
+func ResErrFrom(err error) pkg.Render {
+	var be BizError
+	if errors.As(err, &be) {
+		return ResErr(be.BizCode(), be.BizMsg())
+	}
+	logger.Errorf("unexpected error: %v", err)
+	return ResErr(base.Error, base.ErrorMsg(base.Error))
+}
+
 func Success(data map[string]interface{}) pkg.Render {
 	return pkg.JSON{
 		Data: &Response{
@@ -37,3 +59,16 @@ func Succ(data interface{}) pkg.Render {
 		},
 	}
 }
+
+// 调用方卖家id, header 优先
+func SellerID(c *gin.Context) string {
+	if id := c.GetHeader("X-Seller-Id"); id != "" {
+		return id
+	}
+	return c.Query("seller_id")
+}
+
+// 解析可选的 TOP 格式时间(东八区 yyyy-MM-dd HH:mm:ss), 为空时返回零值
+func ParseOptionalTime(s string) (time.Time, error) {
+	return toptime.ParseOptional(s)
+}
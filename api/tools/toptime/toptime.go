@@ -0,0 +1,21 @@
+// TOP 接口的时间格式, 不依赖其它包, 供 service 和 tools 共用
+package toptime
+
+import "time"
+
+const Layout = "2006-01-02 15:04:05"
+
+// TOP 接口时间均为东八区
+var Location = time.FixedZone("CST", 8*3600)
+
+func Parse(s string) (time.Time, error) {
+	return time.ParseInLocation(Layout, s, Location)
+}
+
+// 为空时返回零值
+func ParseOptional(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return Parse(s)
+}
@@ -4,24 +4,24 @@ import (
 	"gitlab.xfq.com/tech-lab/dionysus"
 	"gitlab.xfq.com/tech-lab/dionysus/cmd/gincmd"
 	"gitlab.xfq.com/tech-lab/dionysus/pkg/conf"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/orm"
+	"gitlab.xfq.com/tech-lab/dionysus/pkg/rabbitmq"
 	dredis "gitlab.xfq.com/tech-lab/dionysus/pkg/redis"
 	"go.uber.org/dig"
 	"log"
-	itemsHandler "tbTool/api/handler/items"
+	"tbTool/api/container"
 	"tbTool/api/routers"
-	"tbTool/api/service/items"
+	"tbTool/api/service/alerts"
+	"tbTool/api/service/auth"
+	"tbTool/api/service/listings"
+	"tbTool/api/service/quota"
+	"tbTool/api/service/shop"
+	"tbTool/api/service/snapshots"
+	"tbTool/api/service/tmc"
+	"tbTool/api/service/top"
+	"tbTool/api/service/trades"
 )
 
-func initContainer() *dig.Container {
-	c := dig.New()
-
-	itemSrvErr := c.Provide(items.NewItemServiceImpl)
-	itemHandErr := c.Provide(itemsHandler.NewItemsOnSaleGetHandler)
-	log.Fatalf("initContainer start items result:%v,%v", itemSrvErr, itemHandErr)
-
-	return c
-}
-
 func main() {
 	g := gincmd.New()
 
@@ -39,17 +39,78 @@ func main() {
 		log.Println("Reg pre run func err:", err)
 	}
 
-	err = g.RegPreRunFunc("watch.mysql", 3, func() error {
-		return conf.StartWatchConfig("watch.mysql")
+	err = g.RegPreRunFunc(shop.WatchPrefix, 3, func() error {
+		if err := shop.LoadDefaultsFromConfigFile(); err != nil {
+			return err
+		}
+		if err := conf.RegisterEtcdWatch(&shop.DefaultsEvent{Prefix: shop.DefaultsWatchPrefix}); err != nil {
+			return err
+		}
+		if err := shop.LoadFromConfigFile(); err != nil {
+			return err
+		}
+		return conf.RegisterEtcdWatch(&shop.Event{Prefix: shop.WatchPrefix})
+	})
+	if err != nil {
+		log.Println("Reg pre run func err:", err)
+	}
+
+	err = g.RegPreRunFunc(quota.WatchPrefix, 4, func() error {
+		if err := quota.LoadFromConfigFile(); err != nil {
+			return err
+		}
+		top.UseLimiter(quota.NewRedisLimiter())
+		return conf.RegisterEtcdWatch(&quota.Event{Prefix: quota.WatchPrefix})
 	})
 	if err != nil {
 		log.Println("Reg pre run func err:", err)
 	}
-	
-	_ = g.RegPreRunFunc("initContainer", 5, func() error {
+
+	err = g.RegPreRunFunc(auth.WatchPrefix, 5, func() error {
+		if err := auth.LoadFromConfigFile(); err != nil {
+			return err
+		}
+		return conf.RegisterEtcdWatch(&auth.Event{Prefix: auth.WatchPrefix})
+	})
+	if err != nil {
+		log.Println("Reg pre run func err:", err)
+	}
+
+	err = g.RegPreRunFunc(alerts.WatchPrefix, 6, func() error {
+		if err := alerts.LoadChannelsFromConfigFile(); err != nil {
+			return err
+		}
+		if err := conf.RegisterEtcdWatch(&alerts.ChannelEvent{Prefix: alerts.ChannelWatchPrefix}); err != nil {
+			return err
+		}
+		if err := alerts.LoadFromConfigFile(); err != nil {
+			return err
+		}
+		return conf.RegisterEtcdWatch(&alerts.Event{Prefix: alerts.WatchPrefix})
+	})
+	if err != nil {
+		log.Println("Reg pre run func err:", err)
+	}
+
+	err = g.RegPreRunFunc("watch.mysql", 7, func() error {
+		return conf.RegisterEtcdWatch(orm.NewOrmEvent("watch.mysql"))
+	})
+	if err != nil {
+		log.Println("Reg pre run func err:", err)
+	}
+
+	err = g.RegPreRunFunc("watch.rabbitmq", 8, func() error {
+		return conf.RegisterEtcdWatch(rabbitmq.GetRabbitEvent("watch.rabbitmq"))
+	})
+	if err != nil {
+		log.Println("Reg pre run func err:", err)
+	}
+
+	var c *dig.Container
+	_ = g.RegPreRunFunc("initContainer", 9, func() error {
 		//依赖注入
 		log.Println("initContainer start")
-		c := initContainer()
+		c = container.New()
 
 		//路由注入
 		log.Println("RegisterRouter start step")
@@ -57,5 +118,29 @@ func main() {
 		return nil
 	})
 
+	//后台任务
+	_ = g.RegPreRunFunc("jobs", 10, func() error {
+		return c.Invoke(func(j *trades.SyncJob, sj *snapshots.SnapshotJob, tj *tmc.Job, lj *listings.ScheduleJob) error {
+			if err := j.Start(); err != nil {
+				return err
+			}
+			if err := sj.Start(); err != nil {
+				return err
+			}
+			if err := lj.Start(); err != nil {
+				return err
+			}
+			return tj.Start()
+		})
+	})
+	_ = g.RegPostRunFunc("jobs", 1, func() error {
+		return c.Invoke(func(j *trades.SyncJob, sj *snapshots.SnapshotJob, tj *tmc.Job, lj *listings.ScheduleJob) error {
+			_ = tj.Stop()
+			_ = lj.Stop()
+			_ = sj.Stop()
+			return j.Stop()
+		})
+	})
+
 	dionysus.Start("gapi", g)
 }
@@ -4,6 +4,10 @@ go 1.16
 
 require (
 	github.com/gin-gonic/gin v1.8.1
+	github.com/go-playground/validator/v10 v10.10.0
+	github.com/go-redis/redis/v7 v7.2.0
+	github.com/gorilla/websocket v1.4.1
+	github.com/jinzhu/gorm v1.9.13
 	gitlab.xfq.com/tech-lab/dionysus v0.0.0-00010101000000-000000000000
 	go.uber.org/dig v1.14.1
 )
@@ -2,15 +2,22 @@ package request
 
 import (
 	"bytes"
+	"context"
+	"fmt"
 	"io/ioutil"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptrace"
+	"net/textproto"
+	"net/url"
+	"strings"
 	"time"
 )
 
 type Options struct {
 	Headers   map[string]string
 	WithTrace bool
+	Ctx       context.Context
 }
 
 type Option func(options *Options)
@@ -27,6 +34,12 @@ func WithTrace(trace bool) Option {
 	}
 }
 
+func WithContext(ctx context.Context) Option {
+	return func(options *Options) {
+		options.Ctx = ctx
+	}
+}
+
 func Post(url string, body []byte, timeout time.Duration, retries int, setters ...Option) (*http.Response, []byte, error) {
 
 	args := &Options{}
@@ -35,7 +48,7 @@ func Post(url string, body []byte, timeout time.Duration, retries int, setters .
 		setter(args)
 	}
 
-	req, err := http.NewRequest(http.MethodPost, url, ioutil.NopCloser(bytes.NewBuffer(body)))
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
 
 	if err != nil {
 		return nil, nil, err
@@ -44,19 +57,88 @@ func Post(url string, body []byte, timeout time.Duration, retries int, setters .
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("User-Agent", "gapi-request")
 
-	if args.Headers != nil {
-		for k, v := range args.Headers {
-			req.Header.Set(k, v)
+	return do(req, args, timeout, retries)
+}
+
+// application/x-www-form-urlencoded 表单提交
+func PostForm(rawUrl string, values url.Values, timeout time.Duration, retries int, setters ...Option) (*http.Response, []byte, error) {
+	args := &Options{}
+
+	for _, setter := range setters {
+		setter(args)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, rawUrl, strings.NewReader(values.Encode()))
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded;charset=utf-8")
+	req.Header.Set("User-Agent", "gapi-request")
+
+	return do(req, args, timeout, retries)
+}
+
+// multipart/form-data 中的文件
+type File struct {
+	Field       string
+	FileName    string
+	ContentType string
+	Data        []byte
+}
+
+// multipart/form-data 提交, 请求体在内存中构造, 重试时可重放
+func PostMultipart(rawUrl string, values url.Values, files []File, timeout time.Duration, retries int, setters ...Option) (*http.Response, []byte, error) {
+	args := &Options{}
+
+	for _, setter := range setters {
+		setter(args)
+	}
+
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	for k, vs := range values {
+		for _, v := range vs {
+			if err := w.WriteField(k, v); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	for _, f := range files {
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, quoteEscaper.Replace(f.Field), quoteEscaper.Replace(f.FileName)))
+		contentType := f.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
 		}
+		h.Set("Content-Type", contentType)
+		part, err := w.CreatePart(h)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, err := part.Write(f.Data); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, nil, err
 	}
 
-	if args.WithTrace {
-		return toRequestWithTrace(req, 5*time.Second, 3)
+	req, err := http.NewRequest(http.MethodPost, rawUrl, bytes.NewReader(body.Bytes()))
+
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return toRequest(req, timeout, retries)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	req.Header.Set("User-Agent", "gapi-request")
+
+	return do(req, args, timeout, retries)
 }
 
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
 func Get(url string, timeout time.Duration, retries int, setters ...Option) (*http.Response, []byte, error) {
 	args := &Options{}
 
@@ -72,12 +154,20 @@ func Get(url string, timeout time.Duration, retries int, setters ...Option) (*ht
 
 	req.Header.Set("User-Agent", "massage-request")
 
+	return do(req, args, timeout, retries)
+}
+
+func do(req *http.Request, args *Options, timeout time.Duration, retries int) (*http.Response, []byte, error) {
 	if args.Headers != nil {
 		for k, v := range args.Headers {
 			req.Header.Set(k, v)
 		}
 	}
 
+	if args.Ctx != nil {
+		req = req.WithContext(args.Ctx)
+	}
+
 	if args.WithTrace {
 		return toRequestWithTrace(req, 5*time.Second, 3)
 	}
@@ -95,8 +185,16 @@ func toRequest(req *http.Request, timeout time.Duration, retries int) (*http.Res
 	for retries > 0 {
 		resp, reqErr = cli.Do(req)
 		if reqErr != nil {
-
+			if req.Context().Err() != nil {
+				break
+			}
 			retries--
+			// 重试前重置请求体
+			if retries > 0 && req.GetBody != nil {
+				if req.Body, reqErr = req.GetBody(); reqErr != nil {
+					break
+				}
+			}
 		} else {
 			break
 		}
@@ -115,10 +213,10 @@ func toRequest(req *http.Request, timeout time.Duration, retries int) (*http.Res
 }
 
 func toRequestWithTrace(req *http.Request, _ time.Duration, _ int) (*http.Response, []byte, error) {
-	var connect, dns time.Time
+	//var connect, dns time.Time
 	trace := &httptrace.ClientTrace{
 		DNSStart: func(dsi httptrace.DNSStartInfo) {
-			dns = time.Now()
+			//dns = time.Now()
 		},
 		DNSDone: func(ddi httptrace.DNSDoneInfo) {
 			//logger.WithField("uniqueId", requestId).
@@ -127,7 +225,9 @@ func toRequestWithTrace(req *http.Request, _ time.Duration, _ int) (*http.Respon
 			//	Info("dns end:", time.Now())
 		},
 
-		ConnectStart: func(network, addr string) { connect = time.Now() },
+		ConnectStart: func(network, addr string) {
+			//connect = time.Now()
+		},
 		ConnectDone: func(network, addr string, err error) {
 			//logger.WithField("uniqueId", requestId).WithField("module", "server_request").
 			//	WithField("useTime", time.Since(connect).Seconds()).
@@ -154,4 +254,4 @@ func toRequestWithTrace(req *http.Request, _ time.Duration, _ int) (*http.Respon
 		return nil, nil, err
 	}
 	return resp, rs, nil
-}
\ No newline at end of file
+}